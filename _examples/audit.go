@@ -0,0 +1,43 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func auditExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the audit file, rotated every 100MB or every day, keeping 30 files
+	w, err := client.NewRotatingFileWriter("payments-audit.jsonl")
+	if err != nil {
+		panic(err)
+	}
+	w = w.WithMaxSize(100 << 20).WithMaxAge(24 * time.Hour).WithMaxBackups(30)
+
+	// create the audit sink, no record is lost when the buffer is full
+	sink := client.NewAuditSink(w, client.NewRedactor()).
+		WithBufferSize(4096).
+		WithFullPolicy(client.AuditBlock).
+		WithErrorHandler(func(err error) {
+			logger.WithError(err).Error("audit write failed")
+		})
+	defer sink.Close()
+
+	// create the client
+	c := client.NewClient(logger).WithAuditSink(sink)
+
+	// perform the request
+	result, err := c.Post(context.Background(), "https://payments.api/charges", "application/json", map[string]interface{}{"amount": 10})
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
@@ -0,0 +1,29 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func authRefreshExample(auth *client.RefreshableBearerAuthenticator) {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client, on "401 Unauthorized" the token is refreshed by a single
+	// goroutine and every request is replayed once with the new token
+	c := client.NewClient(logger).
+		WithCredentialProvider(auth).
+		WithAuthRefresh(auth.Refresh)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
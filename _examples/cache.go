@@ -0,0 +1,29 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func cacheExample() {
+	// keep up to 64 MiB of responses in memory, client.NewDiskCacheStore
+	// keeps them across restarts
+	store := client.NewMemoryCacheStore(64 << 20)
+
+	// create the client
+	c := client.NewClient(logrus.New()).WithCache(store)
+
+	// the second request is served from the cache while the response is fresh
+	for i := 0; i < 2; i++ {
+		result, err := c.Get(context.Background(), "https://test.api/products/1")
+		if err != nil {
+			panic(err)
+		}
+
+		// hit, revalidated or miss
+		fmt.Printf("cache: %s\n", result.CacheStatus)
+	}
+}
@@ -0,0 +1,33 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func coalescingExample() {
+	// share the identical GETs in flight, the requests of different tenants stay apart
+	c := client.NewClient(logrus.New()).WithRequestCoalescing("X-Tenant")
+
+	// a single request reaches the server
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := c.Get(context.Background(), "https://test.api/products/1")
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			body, _ := result.GetStringBody()
+			fmt.Printf("coalesced: %t, %d bytes\n", result.Coalesced, len(body))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package _examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func compressionExample() {
+	// register another content coding, e.g. from a brotli sub-package
+	client.RegisterContentDecoder("x-upper", func(r io.Reader) (io.ReadCloser, error) {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(strings.NewReader(strings.ToUpper(string(b)))), nil
+	})
+
+	// decode the responses up to 10 MiB, gzip the request bodies above 1 KiB
+	c := client.NewClient(logrus.New()).
+		WithResponseDecompression(10 << 20).
+		WithRequestCompression(1 << 10)
+
+	result, err := c.Post(context.Background(), "https://test.api/products", "application/json", map[string]string{
+		"description": strings.Repeat("a large description ", 100),
+	})
+	if errors.Is(err, client.ErrDecodedBodyTooLarge) {
+		fmt.Println("the response is a decompression bomb")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	body, _ := result.GetStringBody()
+	fmt.Println(body)
+}
@@ -0,0 +1,32 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func conditionalExample() {
+	// remember the responses with an ETag or a Last-Modified validator
+	c := client.NewClient(logrus.New()).
+		WithConditionalRequests(client.NewMemoryCacheStore(16 << 20))
+
+	// poll the document, an unchanged one isn't downloaded again
+	for i := 0; i < 3; i++ {
+		result, err := c.Get(context.Background(), "https://test.api/catalog.json")
+		if err != nil {
+			panic(err)
+		}
+
+		var catalog map[string]interface{}
+		if err := result.UnmarshalJSONResponse(&catalog); err != nil {
+			panic(err)
+		}
+		fmt.Printf("not modified: %t, %d entries\n", result.NotModified, len(catalog))
+
+		time.Sleep(time.Minute)
+	}
+}
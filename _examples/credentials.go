@@ -0,0 +1,52 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func credentialStoreExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the credential store
+	store := client.NewCredentialStore()
+
+	// the products API uses a token mounted as a Kubernetes secret
+	if err := store.Add("https://products.api/v1", client.NewFileCredentials("Bearer", "/var/run/secrets/products/token")); err != nil {
+		panic(err)
+	}
+
+	// the orders API uses a token from the environment
+	if err := store.Add("orders.api", client.NewEnvCredentials("Bearer", "ORDERS_API_TOKEN")); err != nil {
+		panic(err)
+	}
+
+	// the legacy API uses the credentials from ~/.netrc
+	if err := store.Add("legacy.api", client.NewNetrcCredentials("")); err != nil {
+		panic(err)
+	}
+
+	// create the client
+	c := client.NewClient(logger).WithCredentialStore(store)
+
+	// perform the requests
+	result, err := c.Get(context.Background(), "https://products.api/v1/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+
+	result, err = c.Get(context.Background(), "https://orders.api/orders/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
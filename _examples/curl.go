@@ -0,0 +1,49 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func curlExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client, every attempt is logged as a curl command with the credentials masked
+	c := client.NewClient(logger).
+		WithBearerAuth("token").
+		WithCurlLogging(client.NewRedactor())
+
+	// create the request
+	req, err := c.NewRequest(context.Background(), http.MethodPost, "https://test.api/products", map[string]string{"name": "product"})
+	if err != nil {
+		panic(err)
+	}
+	req.SetHeader("Content-Type", "application/json")
+
+	// render the reproduction, including the auth the client applies
+	cmd, err := c.Curl(req, client.NewRedactor())
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(cmd)
+
+	// or create a request from a curl command
+	req, err = c.NewRequestFromCurl(context.Background(), `curl -X POST https://test.api/products -H 'Content-Type: application/json' -d '{"name":"product"}'`)
+	if err != nil {
+		panic(err)
+	}
+
+	// perform the request
+	result, err := c.Do(req)
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
@@ -0,0 +1,34 @@
+package _examples
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func deprecationExample() {
+	logger := logrus.New()
+
+	// create the tracker, warning the first time an endpoint is seen deprecated
+	tracker := client.NewDeprecationTracker().
+		WithCallback(func(endpoint string, d client.Deprecation) {
+			logger.Warnf("%s is deprecated, sunset: %s, see %s", endpoint, d.Sunset, d.Link)
+		})
+
+	// create the client
+	c := client.NewClient(logger).WithDeprecationTracker(tracker)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/v1/products/1")
+	if err != nil {
+		panic(err)
+	}
+	if result.Deprecation != nil {
+		logger.Infof("sunset on %s", result.Deprecation.Sunset)
+	}
+
+	// expose the report on a debug endpoint
+	http.Handle("/debug/deprecations", tracker.Handler())
+}
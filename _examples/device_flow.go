@@ -0,0 +1,45 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func deviceFlowExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client
+	c := client.NewClient(logger)
+
+	// create the device flow
+	flow := client.NewDeviceFlow(c, client.DeviceFlowConfig{
+		ClientID:               "my-cli",
+		Scopes:                 []string{"products:read"},
+		DeviceAuthorizationURL: "https://auth.test.api/oauth/device/code",
+		TokenURL:               "https://auth.test.api/oauth/token",
+		Prompt: func(ctx context.Context, auth *client.DeviceAuthorization) error {
+			fmt.Printf("Open %s and enter the code %s\n", auth.VerificationURI, auth.UserCode)
+			return nil
+		},
+	})
+
+	// the token is cached on disk, so the user logs in only once
+	auth := client.NewRefreshableBearerAuthenticator(flow).
+		WithTokenCache(client.NewFileTokenCache("/home/user/.config/my-cli/token.json"))
+
+	// set the authenticator, the requests of the flow never carry the token
+	c = c.WithCredentialProvider(auth)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
@@ -0,0 +1,45 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func discoveryExample() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool, err := client.NewEndpointPool(client.LeastOutstandingStrategy())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// follow the _products._tcp.services.internal SRV records every 30 seconds,
+	// the lowest priority gets the requests in proportion to the weights
+	srv := client.NewSRVDiscoverer(client.NewDNSServerResolver("10.0.0.2:53"), "products", "tcp", "services.internal", "http", "/api/")
+	err = pool.StartDiscovery(ctx, srv, 30*time.Second, func(err error) {
+		fmt.Println("discovery failed, keeping the endpoints:", err)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// or read them from a JSON registry file
+	// pool.StartDiscovery(ctx, client.NewFileDiscoverer("/etc/registry/products.json"), time.Minute, nil)
+
+	c := client.NewClient(logrus.New()).WithEndpointPool(pool)
+
+	result, err := c.Get(ctx, "products/1")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	body, _ := result.GetStringBody()
+	fmt.Println(body)
+}
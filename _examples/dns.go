@@ -0,0 +1,35 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func dnsExample() {
+	// resolve with DNS-over-HTTPS, caching the answers for a minute and the
+	// unknown hosts for 10 seconds
+	doh := client.NewDoHResolver(client.NewClient(logrus.New()), "https://cloudflare-dns.com/dns-query")
+
+	c := client.NewClient(logrus.New()).
+		WithResolver(client.NewDNSCache(doh, time.Minute, 10*time.Second)).
+		// send the API traffic to the green deployment, like curl --resolve
+		WithHostOverride("test.api:443", "10.0.1.10", "10.0.1.11")
+
+	result, err := c.Get(context.Background(), "https://test.api/products")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	body, _ := result.GetStringBody()
+	fmt.Println(body)
+
+	// or query a specific DNS server
+	c = client.NewClient(logrus.New()).WithResolver(client.NewDNSServerResolver("10.0.0.2:53"))
+	if _, err := c.Get(context.Background(), "https://test.api/products"); err != nil {
+		fmt.Println(err)
+	}
+}
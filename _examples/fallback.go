@@ -0,0 +1,38 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func fallbackExample() {
+	// serve an empty recommendation list when the service is down
+	routes := client.NewFallbackStore()
+	err := routes.Add("https://test.api/recommendations", func(req *client.Request, _ *client.Response, _ error) (*client.Response, error) {
+		return client.NewSyntheticResponse(req, http.StatusOK, nil, []byte(`[]`)), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// the cache serves the last good response on errors when the server
+	// allowed it with "Cache-Control: stale-if-error=<seconds>"
+	c := client.NewClient(logrus.New()).
+		WithCache(client.NewMemoryCacheStore(16 << 20)).
+		WithFallbackStore(routes)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/recommendations/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// the stale and the fallback responses are marked as degraded
+	if result.Degraded {
+		fmt.Printf("degraded (cache: %s): %v\n", result.CacheStatus, result.DegradedCause)
+	}
+}
@@ -0,0 +1,41 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func harExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the recorder, masking the credentials and the X-Api-Key header
+	recorder := client.NewHARRecorder(client.NewRedactor().WithHeaders("X-Api-Key"))
+
+	// create the client
+	c := client.NewClient(logger).WithHARRecorder(recorder)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+
+	// save the session, it can be loaded in the browser devtools
+	if saveErr := recorder.WriteFile("session.har"); saveErr != nil {
+		panic(saveErr)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	// or export a single response, including every retry attempt
+	har, err := result.HAR(client.NewRedactor())
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the archive
+	fmt.Println(len(har.Log.Entries))
+}
@@ -0,0 +1,49 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func jwtAuthExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// load the service key
+	pemKey, err := ioutil.ReadFile("/etc/service/key.pem")
+	if err != nil {
+		panic(err)
+	}
+	key, err := client.ParsePrivateKeyPEM(pemKey)
+	if err != nil {
+		panic(err)
+	}
+
+	// create the JWT authenticator, the "aud" claim defaults to the request host
+	jwtAuth, err := client.NewJWTAuthenticator(client.JWTAlgorithmES256, key)
+	if err != nil {
+		panic(err)
+	}
+	jwtAuth = jwtAuth.
+		WithKeyID("service-key-1").
+		WithIssuer("products-service").
+		WithSubject("products-service").
+		WithTTL(5 * time.Minute)
+
+	// create the client
+	c := client.NewClient(logger).WithCredentialProvider(jwtAuth)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
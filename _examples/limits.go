@@ -0,0 +1,44 @@
+package _examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func limitsExample() {
+	// refuse the bodies above 1 MiB, read up to 64 KiB of the discarded ones
+	c := client.NewClient(logrus.New()).
+		WithMaxResponseSize(1 << 20).
+		WithDrainLimit(64 << 10)
+
+	result, err := c.Get(context.Background(), "https://test.api/products")
+	if errors.Is(err, client.ErrBodyTooLarge) {
+		fmt.Println("the products list is too large")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	body, _ := result.GetStringBody()
+	fmt.Println(body)
+
+	// the report is allowed to be larger
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "https://test.api/reports/yearly", nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	result, err = c.Do(req.WithMaxResponseSize(50 << 20))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	report, _ := result.GetBody()
+	fmt.Printf("%d bytes\n", len(report))
+}
@@ -0,0 +1,36 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func metricsExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the metrics collector
+	metrics := client.NewInMemoryMetrics("http_client")
+
+	// expose the metrics to Prometheus
+	http.Handle("/metrics", metrics.Handler())
+	go func() {
+		_ = http.ListenAndServe(":9090", nil)
+	}()
+
+	// create the client
+	c := client.NewClient(logger).WithMetricsCollector(metrics)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
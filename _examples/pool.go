@@ -0,0 +1,39 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func poolExample() {
+	// balance between the replicas, ejecting a replica failing 3 times in a row for a minute
+	pool, err := client.NewEndpointPool(client.PowerOfTwoChoicesStrategy(),
+		"http://10.0.1.10:8080/api/",
+		"http://10.0.1.11:8080/api/",
+		"http://10.0.1.12:8080/api/",
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pool.WithOutlierDetection(3, time.Minute)
+
+	c := client.NewClient(logrus.New()).WithEndpointPool(pool)
+
+	// the relative URL is resolved against the picked replica, a retry picks another one
+	result, err := c.Get(context.Background(), "products/1")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	body, _ := result.GetStringBody()
+	fmt.Println(body)
+
+	for _, e := range pool.Endpoints() {
+		fmt.Printf("%s: %d in flight, ejected: %t\n", e.URL, e.Outstanding(), e.Ejected())
+	}
+}
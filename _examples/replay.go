@@ -0,0 +1,41 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func replayExample(failed *client.Response) {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client
+	c := client.NewClient(logger).WithBearerAuth("sandbox-token")
+
+	// create the replayer, sending the captured requests to the sandbox
+	sandbox, _ := url.Parse("https://sandbox.test.api")
+	replayer := client.NewReplayer(c).WithBaseURL(sandbox).WithConcurrency(4)
+
+	// re-send the request of the last attempt of a failed call
+	result, err := replayer.Replay(context.Background(), failed.AttemptDumps[len(failed.AttemptDumps)-1])
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(result.GetStatusCode())
+
+	// replay an audit log and compare the responses
+	results, err := replayer.WithRedactor(client.NewRedactor()).ReplayAuditFile(context.Background(), "payments-audit.jsonl")
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range results {
+		if !r.Match() {
+			fmt.Printf("line %d: %s %s differs (status match: %t, body match: %t, error: %v)\n",
+				r.Line, r.Record.Method, r.Record.URL, r.StatusMatch, r.BodyMatch, r.Err)
+		}
+	}
+}
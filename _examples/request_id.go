@@ -0,0 +1,32 @@
+package _examples
+
+import (
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func requestIDExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client, sending the ULID of every call in the X-Correlation-ID header
+	c := client.NewClient(logger).
+		WithRequestIDHeader("X-Correlation-ID").
+		WithRequestIDGenerator(client.NewULID)
+
+	// the calls made while handling a request carry its ID
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := c.Get(r.Context(), "https://test.api/products/1")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(result.GetStatusCode())
+	})
+
+	// store the ID of the incoming requests in their context
+	http.Handle("/products", client.RequestIDMiddleware("X-Correlation-ID", client.NewULID)(handler))
+	_ = http.ListenAndServe(":8080", nil)
+}
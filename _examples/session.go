@@ -0,0 +1,54 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func sessionExample() {
+	// create the logger
+	logger := logrus.New()
+
+	// create the cookie jar, the cookies saved by a previous run are loaded
+	jar, err := client.NewPersistentCookieJar("/tmp/admin-cookies.json")
+	if err != nil {
+		panic(err)
+	}
+
+	// create the client
+	c := client.NewClient(logger).WithCookieJar(jar)
+
+	// create the session, the CSRF token is read from the "csrftoken" cookie
+	s := client.NewSession(c, client.CSRFConfig{CookieName: "csrftoken"})
+
+	// login
+	req, err := c.NewRequest(context.Background(), http.MethodPost, "https://admin.test.api/login", map[string]string{
+		"username": "username",
+		"password": "password",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := s.Login(req); err != nil {
+		panic(err)
+	}
+
+	// perform the request, the CSRF token is sent in the "X-CSRF-Token" header
+	result, err := c.Delete(context.Background(), "https://admin.test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+
+	// save the cookies for the next run
+	if err := jar.Save(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,45 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func streamingExample() {
+	c := client.NewClient(logrus.New())
+
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "https://test.api/exports/orders.csv", nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// return once the headers arrive, fail when the server stalls for 30 seconds
+	result, err := c.Do(req.WithStreaming(30 * time.Second))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer result.Stream.Close()
+
+	f, err := os.Create("orders.csv")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+
+	// copy the export without holding it in memory
+	if _, err := io.Copy(f, result.Stream); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("downloaded %d of %d bytes\n", result.Stream.BytesRead(), result.Stream.Size())
+}
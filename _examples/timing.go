@@ -0,0 +1,30 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func timingExample() {
+	// create the logger, the timings are logged at the debug level
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+
+	// create the client
+	c := client.NewClient(logger)
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// inspect where the time went on every attempt
+	for _, t := range result.Timings {
+		fmt.Printf("attempt %d: dns %s, connect %s, tls %s, server %s, backoff %s, reused %t, remote %s\n",
+			t.Attempt, t.DNSLookup, t.Connect, t.TLSHandshake, t.ServerProcessing, t.Backoff, t.ConnReused, t.RemoteAddr)
+	}
+}
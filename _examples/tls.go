@@ -0,0 +1,33 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func tlsExample() {
+	logger := logrus.New()
+
+	// create the client, warning about the certificates expiring within 30 days
+	c := client.NewClient(logger).
+		WithCertificateExpiryWarning(30, func(host string, cert client.CertificateInfo, remaining time.Duration) {
+			logger.Warnf("%s: certificate %s expires in %s", host, cert.Subject, remaining.Round(time.Hour))
+		})
+
+	// perform the request
+	result, err := c.Get(context.Background(), "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// inspect the connection
+	info := result.TLS
+	fmt.Printf("%s, %s, alpn %q, ocsp %s\n", info.VersionName, info.CipherSuiteName, info.NegotiatedProtocol, info.OCSPStatus)
+	for _, cert := range info.PeerCertificates {
+		fmt.Printf("%s issued by %s, SANs %v, expires %s\n", cert.Subject, cert.Issuer, cert.DNSNames, cert.NotAfter)
+	}
+}
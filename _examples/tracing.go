@@ -0,0 +1,34 @@
+package _examples
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+)
+
+func tracingExample(inbound *http.Request) {
+	// create the logger
+	logger := logrus.New()
+
+	// create the client. With a tracer (e.g. otelclient.NewTracer(provider)) the
+	// client opens a span per call and a child span per attempt
+	c := client.NewClient(logger)
+
+	// continue the trace of the incoming request
+	ctx := context.Background()
+	if sc, err := client.ParseTraceParent(inbound.Header.Get("traceparent"), inbound.Header.Get("tracestate")); err == nil {
+		ctx = client.ContextWithSpanContext(ctx, sc)
+	}
+
+	// perform the request, the trace context is propagated in the headers
+	result, err := c.Get(ctx, "https://test.api/products/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// do something with the result
+	fmt.Println(result)
+}
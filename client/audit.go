@@ -0,0 +1,281 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// default number of records buffered by the AuditSink
+const defaultAuditBufferSize int = 1024
+
+// AuditFullPolicy defines what the AuditSink does when its buffer is full
+type AuditFullPolicy int
+
+// Audit full buffer policies
+const (
+	// AuditDropNewest drops the record being added
+	AuditDropNewest AuditFullPolicy = iota
+
+	// AuditDropOldest drops the oldest buffered record to make room
+	AuditDropOldest
+
+	// AuditBlock blocks the call until there is room, so no record is lost
+	AuditBlock
+)
+
+// AuditRecord is the audit line of a logical call. The headers and the
+// bodies are the ones of the last attempt
+type AuditRecord struct {
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"request_id,omitempty"`
+	Method    string        `json:"method"`
+	URL       string        `json:"url"`
+	Status    int           `json:"status"`
+	Attempts  int           `json:"attempts"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+
+	Timings []AttemptTiming `json:"timings,omitempty"`
+
+	RequestHeaders      http.Header `json:"request_headers,omitempty"`
+	RequestBody         string      `json:"request_body,omitempty"`
+	RequestBodyEncoding string      `json:"request_body_encoding,omitempty"`
+
+	ResponseHeaders      http.Header `json:"response_headers,omitempty"`
+	ResponseBody         string      `json:"response_body,omitempty"`
+	ResponseBodyEncoding string      `json:"response_body_encoding,omitempty"`
+}
+
+// auditEvent holds the data of a call until the AuditSink renders its record
+type auditEvent struct {
+	start    time.Time
+	method   string
+	url      *url.URL
+	attempts int
+	duration time.Duration
+	resp     *Response
+	err      error
+}
+
+// AuditSink writes an AuditRecord, as a JSON line, for every logical call of
+// the client. The records are written asynchronously through a bounded buffer
+type AuditSink struct {
+	w        io.WriteCloser
+	redactor *Redactor
+
+	bufferSize int
+	fullPolicy AuditFullPolicy
+	onError    func(error)
+
+	startOnce sync.Once
+	events    chan auditEvent
+	done      chan struct{}
+
+	// guards events against the sends after Close
+	mu     sync.RWMutex
+	closed bool
+
+	statsMu sync.Mutex
+	dropped uint64
+}
+
+// NewAuditSink creates a new AuditSink writing to the writer, e.g. a
+// RotatingFileWriter, and masking the values matched by the redactor
+func NewAuditSink(w io.WriteCloser, redactor *Redactor) *AuditSink {
+	return &AuditSink{
+		w:          w,
+		redactor:   redactor,
+		bufferSize: defaultAuditBufferSize,
+		fullPolicy: AuditDropNewest,
+		done:       make(chan struct{}),
+	}
+}
+
+// WithBufferSize sets the number of buffered records and returns the AuditSink.
+// It must be set before the first record
+func (s *AuditSink) WithBufferSize(n int) *AuditSink {
+	if n > 0 {
+		s.bufferSize = n
+	}
+	return s
+}
+
+// WithFullPolicy sets the policy applied when the buffer is full and returns the AuditSink
+func (s *AuditSink) WithFullPolicy(p AuditFullPolicy) *AuditSink {
+	s.fullPolicy = p
+	return s
+}
+
+// WithErrorHandler sets the function called with the write errors and returns the AuditSink
+func (s *AuditSink) WithErrorHandler(f func(error)) *AuditSink {
+	s.onError = f
+	return s
+}
+
+// WithAuditSink sets the audit sink and returns the BaseClient
+func (c *BaseClient) WithAuditSink(s *AuditSink) *BaseClient {
+	c.auditSink = s
+	return c
+}
+
+// Dropped returns the number of records dropped because the buffer was full
+// or the sink was closed
+func (s *AuditSink) Dropped() uint64 {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	return s.dropped
+}
+
+// drop counts a dropped record
+func (s *AuditSink) drop() {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	s.dropped++
+}
+
+// start starts the writer goroutine
+func (s *AuditSink) start() {
+	s.startOnce.Do(func() {
+		s.events = make(chan auditEvent, s.bufferSize)
+		go s.run()
+	})
+}
+
+// record queues the event according to the full buffer policy
+func (s *AuditSink) record(e auditEvent) {
+	s.start()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.drop()
+		return
+	}
+
+	switch s.fullPolicy {
+	case AuditBlock:
+		s.events <- e
+	case AuditDropOldest:
+		for {
+			select {
+			case s.events <- e:
+				return
+			default:
+			}
+			select {
+			case <-s.events:
+				s.drop()
+			default:
+			}
+		}
+	default:
+		select {
+		case s.events <- e:
+		default:
+			s.drop()
+		}
+	}
+}
+
+// run renders and writes the queued records
+func (s *AuditSink) run() {
+	defer close(s.done)
+
+	for e := range s.events {
+		b, err := json.Marshal(s.newRecord(e))
+		if err == nil {
+			_, err = s.w.Write(append(b, '\n'))
+		}
+		if err != nil && s.onError != nil {
+			s.onError(err)
+		}
+	}
+}
+
+// Close writes the buffered records and closes the writer
+func (s *AuditSink) Close() error {
+	s.start()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.mu.Unlock()
+
+	<-s.done
+	return s.w.Close()
+}
+
+// newRecord renders the record of the call
+func (s *AuditSink) newRecord(e auditEvent) AuditRecord {
+	r := AuditRecord{
+		Time:     e.start.UTC(),
+		Method:   e.method,
+		URL:      s.redactor.URL(e.url),
+		Attempts: e.attempts,
+		Duration: e.duration,
+	}
+	if e.err != nil {
+		r.Error = e.err.Error()
+	}
+	if e.resp == nil {
+		return r
+	}
+
+	r.RequestID = e.resp.RequestID
+	r.Timings = e.resp.Timings
+	if e.resp.RawResponse != nil {
+		r.Status = e.resp.RawResponse.StatusCode
+	}
+	if len(e.resp.AttemptDumps) == 0 {
+		return r
+	}
+
+	dump := e.resp.AttemptDumps[len(e.resp.AttemptDumps)-1]
+	req, body, err := readRequestDump(dump.RequestDump)
+	if err != nil {
+		return r
+	}
+	r.RequestHeaders = s.redactor.Header(req.Header)
+	r.RequestBody, r.RequestBodyEncoding = encodeAuditBody(s.redactor.Body(req.Header.Get(contentTypeHeaderKey), body))
+
+	if dump.ResponseDump == nil {
+		return r
+	}
+	resp, body, err := readResponseDump(dump.ResponseDump, req)
+	if err != nil {
+		return r
+	}
+	r.ResponseHeaders = s.redactor.Header(resp.Header)
+	r.ResponseBody, r.ResponseBodyEncoding = encodeAuditBody(s.redactor.Body(resp.Header.Get(contentTypeHeaderKey), body))
+
+	return r
+}
+
+// encodeAuditBody returns the body as text, or base64 encoded when it isn't valid UTF-8
+func encodeAuditBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeAuditBody returns the body of the recorded text and encoding
+func decodeAuditBody(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
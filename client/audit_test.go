@@ -0,0 +1,243 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryWriter is an io.WriteCloser keeping the written lines, which blocks
+// the writes while held
+type memoryWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+
+	hold    chan struct{}
+	writing chan struct{}
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if w.hold != nil {
+		w.writing <- struct{}{}
+		<-w.hold
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *memoryWriter) records(t *testing.T) []AuditRecord {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(w.buf.Bytes()))
+	for scanner.Scan() {
+		var r AuditRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+// newHeldWriter returns a memoryWriter blocking the writes until released
+func newHeldWriter() *memoryWriter {
+	return &memoryWriter{hold: make(chan struct{}), writing: make(chan struct{}, 10)}
+}
+
+func (w *memoryWriter) release() {
+	close(w.hold)
+}
+
+func auditEventOf(path string) auditEvent {
+	return auditEvent{method: http.MethodGet, url: &url.URL{Scheme: "http", Host: "api.local", Path: path}}
+}
+
+func auditPaths(records []AuditRecord) []string {
+	var paths []string
+	for _, r := range records {
+		u, _ := url.Parse(r.URL)
+		paths = append(paths, u.Path)
+	}
+	return paths
+}
+
+func TestAuditSink_fullPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		policy  AuditFullPolicy
+		paths   []string
+		dropped uint64
+	}{
+		{AuditDropNewest, []string{"/1", "/2"}, 1},
+		{AuditDropOldest, []string{"/1", "/3"}, 1},
+		{AuditBlock, []string{"/1", "/2", "/3"}, 0},
+	} {
+		w := newHeldWriter()
+		s := NewAuditSink(w, nil).WithBufferSize(1).WithFullPolicy(tc.policy)
+
+		// the first record is being written, the second one fills the buffer
+		s.record(auditEventOf("/1"))
+		<-w.writing
+		s.record(auditEventOf("/2"))
+
+		recorded := make(chan struct{})
+		go func() {
+			s.record(auditEventOf("/3"))
+			close(recorded)
+		}()
+
+		if tc.policy == AuditBlock {
+			select {
+			case <-recorded:
+				t.Fatal("the record should block")
+			case <-time.After(20 * time.Millisecond):
+			}
+			w.release()
+			<-recorded
+		} else {
+			<-recorded
+			w.release()
+		}
+		assert.Nil(t, s.Close())
+
+		assert.True(t, w.closed)
+		assert.Equal(t, tc.paths, auditPaths(w.records(t)), "policy %d", tc.policy)
+		assert.Equal(t, tc.dropped, s.Dropped(), "policy %d", tc.policy)
+
+		// dropped after close
+		s.record(auditEventOf("/4"))
+		assert.Equal(t, tc.dropped+1, s.Dropped())
+		assert.Nil(t, s.Close())
+	}
+}
+
+// failingWriter is an io.WriteCloser failing every write
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) { return 0, errors.New("disk full") }
+
+func (failingWriter) Close() error { return nil }
+
+func TestAuditSink_WithErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	var errs []error
+	s := NewAuditSink(failingWriter{}, nil).WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	s.record(auditEventOf("/"))
+	assert.Nil(t, s.Close())
+	assert.Len(t, errs, 1)
+}
+
+func TestBaseClient_WithAuditSink(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int
+	mux.HandleFunc("/pay", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"p1","access_token":"secret"}`))
+	})
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fw, err := NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	sink := NewAuditSink(fw.WithMaxSize(1<<20), NewRedactor())
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithBearerAuth("token").
+		WithAuditSink(sink)
+
+	resp, err := c.Post(context.Background(), u+"/pay?client_secret=x", jsonContentType, map[string]string{"password": "p", "amount": "10"})
+	assert.Nil(t, err)
+
+	// canceled calls are recorded too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx, u+"/pay")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, sink.Close())
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	assert.Len(t, records, 2)
+
+	r := records[0]
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, u+"/pay?client_secret=REDACTED", r.URL)
+	assert.Equal(t, http.StatusCreated, r.Status)
+	assert.Equal(t, 2, r.Attempts)
+	assert.Len(t, r.Timings, 2)
+	assert.Equal(t, http.StatusBadGateway, r.Timings[0].StatusCode)
+	assert.Equal(t, resp.RequestID, r.RequestID)
+	assert.True(t, r.Duration > 0)
+	assert.Empty(t, r.Error)
+	assert.Equal(t, redactedValue, r.RequestHeaders.Get(authorizationHeaderKey))
+	assert.Equal(t, "2", r.RequestHeaders.Get(attemptHeaderKey))
+	assert.Equal(t, `{"amount":"10","password":"REDACTED"}`, r.RequestBody)
+	assert.Equal(t, jsonContentType, r.ResponseHeaders.Get(contentTypeHeaderKey))
+	assert.Equal(t, `{"access_token":"REDACTED","id":"p1"}`, r.ResponseBody)
+
+	assert.Equal(t, http.MethodGet, records[1].Method)
+	assert.Equal(t, 0, records[1].Status)
+	assert.Contains(t, records[1].Error, "context canceled")
+}
+
+func Test_encodeAuditBody(t *testing.T) {
+	t.Parallel()
+
+	text, encoding := encodeAuditBody([]byte("text"))
+	assert.Equal(t, "text", text)
+	assert.Empty(t, encoding)
+
+	text, encoding = encodeAuditBody([]byte{0xff})
+	assert.Equal(t, "/w==", text)
+	assert.Equal(t, "base64", encoding)
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultAuthRefreshCooldown is the minimum time between two refreshes; a request
+// rejected right after a refresh with the fresh credentials is not refreshed again
+const defaultAuthRefreshCooldown time.Duration = time.Second
+
+// defaultAuthRefreshTimeout bounds a refresh, it doesn't end with the request which started it
+const defaultAuthRefreshTimeout time.Duration = 30 * time.Second
+
+// AuthRefreshFunc refreshes the credentials used by the client, e.g. by fetching
+// a new token which is then returned by the credential provider
+type AuthRefreshFunc func(ctx context.Context) error
+
+// AuthRefreshPredicate checks if the response means the credentials must be refreshed
+type AuthRefreshPredicate func(resp *http.Response) bool
+
+// DefaultAuthRefreshPredicate refreshes the credentials on "401 Unauthorized"
+func DefaultAuthRefreshPredicate(resp *http.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusUnauthorized
+}
+
+// authRefreshCall is an in-flight refresh
+type authRefreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// authRefresher makes sure only one goroutine refreshes the credentials at
+// a time while the others wait for its result
+type authRefresher struct {
+	refresh  AuthRefreshFunc
+	cooldown time.Duration
+	timeout  time.Duration
+
+	mu          sync.Mutex
+	generation  uint64
+	lastRefresh time.Time
+	call        *authRefreshCall
+}
+
+// newAuthRefresher creates a new authRefresher
+func newAuthRefresher(refresh AuthRefreshFunc) *authRefresher {
+	return &authRefresher{
+		refresh:  refresh,
+		cooldown: defaultAuthRefreshCooldown,
+		timeout:  defaultAuthRefreshTimeout,
+	}
+}
+
+// currentGeneration returns the generation of the credentials, it is
+// increased after every successful refresh
+func (r *authRefresher) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// refreshFrom refreshes the credentials of the provided generation. It returns
+// true when the request should be replayed with the new credentials
+func (r *authRefresher) refreshFrom(ctx context.Context, generation uint64) (bool, error) {
+	r.mu.Lock()
+
+	// the credentials were already refreshed since the request was sent
+	if generation < r.generation {
+		r.mu.Unlock()
+		return true, nil
+	}
+
+	call := r.call
+	if call == nil {
+		// the fresh credentials were rejected as well, don't spin
+		if !r.lastRefresh.IsZero() && time.Since(r.lastRefresh) < r.cooldown {
+			r.mu.Unlock()
+			return false, nil
+		}
+
+		call = &authRefreshCall{done: make(chan struct{})}
+		r.call = call
+		r.mu.Unlock()
+
+		// the refresh is shared, it outlives the request which started it
+		go r.run(detachedContext{ctx}, call)
+	} else {
+		r.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	if call.err != nil {
+		return false, call.err
+	}
+	return true, nil
+}
+
+// run refreshes the credentials and releases the callers waiting for the call
+func (r *authRefresher) run(ctx context.Context, call *authRefreshCall) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	call.err = r.refresh(ctx)
+
+	r.mu.Lock()
+	if call.err == nil {
+		r.generation++
+		r.lastRefresh = time.Now()
+	}
+	r.call = nil
+	r.mu.Unlock()
+
+	close(call.done)
+}
+
+// WithAuthRefresh sets the func used to refresh the credentials and returns the BaseClient.
+// When a response matches the refresh predicate (401 by default), exactly one goroutine
+// calls refresh while the concurrent callers wait, then each request is replayed once
+func (c *BaseClient) WithAuthRefresh(refresh AuthRefreshFunc) *BaseClient {
+	if refresh == nil {
+		c.authRefresher = nil
+		return c
+	}
+	c.authRefresher = newAuthRefresher(refresh)
+	return c
+}
+
+// WithAuthRefreshPredicate sets the predicate deciding when the credentials
+// are refreshed and returns the BaseClient. A nil predicate restores the default
+func (c *BaseClient) WithAuthRefreshPredicate(predicate AuthRefreshPredicate) *BaseClient {
+	c.authRefreshPredicate = predicate
+	return c
+}
+
+// shouldRefreshAuth checks if the response asks for refreshed credentials.
+// The requests of the auth flows are never replayed, their failure is the
+// one of the refresh itself
+func (c *BaseClient) shouldRefreshAuth(req *Request, resp *http.Response) bool {
+	if c.authRefresher == nil || resp == nil || req.skipAuth {
+		return false
+	}
+	if c.authRefreshPredicate != nil {
+		return c.authRefreshPredicate(resp)
+	}
+	return DefaultAuthRefreshPredicate(resp)
+}
+
+// authGeneration returns the generation of the credentials applied to a request
+func (c *BaseClient) authGeneration() uint64 {
+	if c.authRefresher == nil {
+		return 0
+	}
+	return c.authRefresher.currentGeneration()
+}
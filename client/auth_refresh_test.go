@@ -0,0 +1,280 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultAuthRefreshPredicate(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, DefaultAuthRefreshPredicate(nil))
+	assert.False(t, DefaultAuthRefreshPredicate(&http.Response{StatusCode: http.StatusForbidden}))
+	assert.True(t, DefaultAuthRefreshPredicate(&http.Response{StatusCode: http.StatusUnauthorized}))
+}
+
+func TestBaseClient_WithAuthRefresh(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("single flight", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		var token atomic.Value
+		token.Store("old")
+
+		var requests int32
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if r.Header.Get(authorizationHeaderKey) != "Bearer new" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write(b)
+		})
+
+		var refreshes int32
+		c := NewClient(logrus.New()).
+			WithRetryMax(0).
+			WithCredentialProvider(CredentialProviderFunc(func(ctx context.Context, _ *url.URL) (*Credential, error) {
+				return &Credential{bearerAuthScheme, token.Load().(string)}, nil
+			})).
+			WithAuthRefresh(func(ctx context.Context) error {
+				atomic.AddInt32(&refreshes, 1)
+				// give the other callers the time to pile up
+				time.Sleep(50 * time.Millisecond)
+				token.Store("new")
+				return nil
+			})
+
+		var wg sync.WaitGroup
+		for n := 0; n < 10; n++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+
+				response, err := c.Post(ctx, u, "application/json", map[string]int{"n": n})
+				assert.Nil(t, err)
+				assert.Equal(t, http.StatusOK, response.GetStatusCode())
+
+				// the body is replayed as well
+				body, err := response.GetStringBody()
+				assert.Nil(t, err)
+				assert.Equal(t, fmt.Sprintf(`{"n":%d}`, n), body)
+			}(n)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+		assert.Equal(t, int32(20), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("bad credential", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		var requests int32
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		var refreshes int32
+		c := NewClient(logrus.New()).
+			WithRetryMax(2).
+			WithBearerAuth("bad").
+			WithAuthRefresh(func(ctx context.Context) error {
+				atomic.AddInt32(&refreshes, 1)
+				return nil
+			})
+
+		// each request is replayed only once
+		response, err := c.Get(ctx, u)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, response.GetStatusCode())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+		// the fresh credential was rejected, so it isn't refreshed again right away
+		response, err = c.Get(ctx, u)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, response.GetStatusCode())
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+	})
+
+	t.Run("refresh error", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		var requests int32
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		c := NewClient(logrus.New()).
+			WithRetryMax(0).
+			WithAuthRefresh(func(ctx context.Context) error {
+				return fmt.Errorf("refresh failed")
+			})
+
+		response, err := c.Get(ctx, u)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, response.GetStatusCode())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("auth flow request", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		// the refresh holds the lock of the authenticator sending the token request
+		var mu sync.Mutex
+		var refreshes int32
+		c := NewClient(logrus.New()).
+			WithRetryMax(0).
+			WithAuthRefresh(func(ctx context.Context) error {
+				atomic.AddInt32(&refreshes, 1)
+				mu.Lock()
+				defer mu.Unlock()
+				return nil
+			})
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		req, err := c.NewRequest(ctx, http.MethodPost, u+"/token", nil)
+		assert.Nil(t, err)
+		req.skipAuth = true
+
+		done := make(chan *Response)
+		go func() {
+			response, err := c.Do(req)
+			assert.Nil(t, err)
+			done <- response
+		}()
+
+		select {
+		case response := <-done:
+			assert.Equal(t, http.StatusUnauthorized, response.GetStatusCode())
+		case <-time.After(5 * time.Second):
+			t.Fatal("the auth flow request waits for the refresh")
+		}
+		assert.Equal(t, int32(0), atomic.LoadInt32(&refreshes))
+	})
+
+	t.Run("custom predicate", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		refreshed := false
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if !refreshed {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		})
+
+		c := NewClient(logrus.New()).
+			WithRetryMax(0).
+			WithAuthRefreshPredicate(func(resp *http.Response) bool {
+				return resp.StatusCode == http.StatusForbidden
+			}).
+			WithAuthRefresh(func(ctx context.Context) error {
+				refreshed = true
+				return nil
+			})
+
+		response, err := c.Get(ctx, u)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.GetStatusCode())
+	})
+}
+
+func Test_authRefresher_refreshFrom(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	var refreshes int
+	r := newAuthRefresher(func(ctx context.Context) error {
+		refreshes++
+		return nil
+	})
+	r.cooldown = 0
+
+	replay, err := r.refreshFrom(ctx, r.currentGeneration())
+	assert.Nil(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, uint64(1), r.currentGeneration())
+
+	// a stale generation is replayed without refreshing
+	replay, err = r.refreshFrom(ctx, 0)
+	assert.Nil(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, 1, refreshes)
+
+	// a canceled waiter gives up
+	r.call = &authRefreshCall{done: make(chan struct{})}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	replay, err = r.refreshFrom(cctx, 1)
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, replay)
+}
+
+func Test_authRefresher_refreshFrom_leaderCanceled(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := newAuthRefresher(func(ctx context.Context) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	})
+
+	// the leader gives up while refreshing
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, err := r.refreshFrom(leaderCtx, 0)
+		leaderErr <- err
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		replay, err := r.refreshFrom(context.Background(), 0)
+		assert.True(t, replay)
+		waiter <- err
+	}()
+
+	cancelLeader()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+
+	// the refresh goes on for the waiter
+	close(release)
+	assert.Nil(t, <-waiter)
+	assert.Equal(t, uint64(1), r.currentGeneration())
+}
@@ -0,0 +1,623 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heuristic freshness, a fraction of the time since the last modification, capped
+const (
+	cacheHeuristicFraction    int64         = 10
+	cacheHeuristicMaxLifetime time.Duration = 24 * time.Hour
+)
+
+// CacheStatus tells how the cache served the response
+type CacheStatus string
+
+// Cache statuses
+const (
+	// CacheMiss is a response received from the server
+	CacheMiss CacheStatus = "miss"
+
+	// CacheHit is a fresh response served from the cache, without a request
+	CacheHit CacheStatus = "hit"
+
+	// CacheRevalidated is a stale response the server confirmed unchanged
+	CacheRevalidated CacheStatus = "revalidated"
+
+	// CacheStale is a stale response served while revalidating in the
+	// background or because the call failed, see RFC 5861
+	CacheStale CacheStatus = "stale"
+)
+
+// the status codes cacheable by default, RFC 9110 section 15.1
+var heuristicallyCacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// staleErrorStatuses are the status codes of the errors which can be
+// answered with a stale response, RFC 5861 section 4
+var staleErrorStatuses = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// the stored headers which aren't updated by a 304 response
+var cacheUpdateSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// WithCache enables the private HTTP cache (RFC 9111) of the GET responses,
+// kept in the store, and returns the BaseClient. The requests with a credential
+// set by the caller or by a provider only share the responses marked public,
+// s-maxage or must-revalidate
+func (c *BaseClient) WithCache(store CacheStore) *BaseClient {
+	c.cacheStore = store
+	c.cacheRevalidate = false
+	return c
+}
+
+// WithConditionalRequests remembers the responses with an ETag or a
+// Last-Modified validator in the store and returns the BaseClient. The next
+// GET of the URL sends If-None-Match / If-Modified-Since, whatever the
+// freshness of the stored response, and a 304 Not Modified is turned into
+// the stored response. It replaces the cache set by WithCache
+func (c *BaseClient) WithConditionalRequests(store CacheStore) *BaseClient {
+	c.cacheStore = store
+	c.cacheRevalidate = true
+	return c
+}
+
+// cacheControl holds the Cache-Control directives, the names are lowercased
+type cacheControl map[string]string
+
+// parseCacheControl returns the Cache-Control directives of the headers
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range h.Values(cacheControlHeaderKey) {
+		for _, directive := range strings.Split(v, ",") {
+			name, value := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = value
+			}
+		}
+	}
+	return cc
+}
+
+// has checks if the directive is set
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// duration returns the value of the delta-seconds directive
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || sec < 0 {
+		return 0, true
+	}
+	return time.Duration(sec) * time.Second, true
+}
+
+// cacheKey returns the store key of the URL
+func cacheKey(u *url.URL) string {
+	return u.String()
+}
+
+// requestCacheKey returns the store key of the request, the relative URL of a
+// request balanced across an endpoint pool so its endpoints share the entries
+func requestCacheKey(req *Request) string {
+	if req.relativeURL != nil {
+		return cacheKey(req.relativeURL)
+	}
+	return cacheKey(req.URL)
+}
+
+// cacheEntry is a stored response
+type cacheEntry struct {
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+
+	// Vary holds the request values of the headers listed by Vary
+	Vary map[string]string `json:"vary,omitempty"`
+
+	// Shared is true when the response can be served to the requests with
+	// a credential of their own, RFC 9111 section 3.5
+	Shared bool `json:"shared,omitempty"`
+
+	// Response is the dump of the response
+	Response []byte `json:"response"`
+
+	resp *http.Response
+	body []byte
+}
+
+// decodeCacheEntry parses the stored entry of the request
+func decodeCacheEntry(b []byte, req *http.Request) (*cacheEntry, error) {
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if e.resp, e.body, err = readResponseDump(e.Response, req); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// encode returns the stored form of the entry
+func (e *cacheEntry) encode() ([]byte, error) {
+	e.resp.Body = ioutil.NopCloser(bytes.NewReader(e.body))
+
+	var err error
+	if e.Response, err = httputil.DumpResponse(e.resp, true); err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// matches checks if the request selects the entry, see Vary
+func (e *cacheEntry) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if strings.Join(req.Header.Values(name), ", ") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// date returns the Date of the response, the time it was received when missing
+func (e *cacheEntry) date() time.Time {
+	if t, err := http.ParseTime(e.resp.Header.Get(dateHeaderKey)); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// age returns the current age of the response, RFC 9111 section 4.2.3
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	var ageValue time.Duration
+	if sec, err := strconv.ParseInt(e.resp.Header.Get(ageHeaderKey), 10, 64); err == nil && sec > 0 {
+		ageValue = time.Duration(sec) * time.Second
+	}
+	correctedAgeValue := ageValue + e.ResponseTime.Sub(e.RequestTime)
+
+	initialAge := apparentAge
+	if correctedAgeValue > initialAge {
+		initialAge = correctedAgeValue
+	}
+	return initialAge + now.Sub(e.ResponseTime)
+}
+
+// lifetime returns the freshness lifetime of the response, RFC 9111 section 4.2.1
+func (e *cacheEntry) lifetime() time.Duration {
+	return freshnessLifetime(e.resp, e.date())
+}
+
+// freshnessLifetime returns the freshness lifetime of the response dated at date
+func freshnessLifetime(resp *http.Response, date time.Time) time.Duration {
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-cache") {
+		return 0
+	}
+	if maxAge, ok := cc.duration("max-age"); ok {
+		return maxAge
+	}
+	if v := resp.Header.Get(expiresHeaderKey); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil || expires.Before(date) {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+
+	// heuristic freshness
+	lastModified, err := http.ParseTime(resp.Header.Get(lastModifiedHeaderKey))
+	if err != nil || !heuristicallyCacheableStatuses[resp.StatusCode] || lastModified.After(date) {
+		return 0
+	}
+	lifetime := date.Sub(lastModified) / time.Duration(cacheHeuristicFraction)
+	if lifetime > cacheHeuristicMaxLifetime {
+		lifetime = cacheHeuristicMaxLifetime
+	}
+	return lifetime
+}
+
+// fresh checks if the entry can be served without revalidation, according to
+// its freshness and the request directives
+func (e *cacheEntry) fresh(reqCC cacheControl, now time.Time) bool {
+	if reqCC.has("no-cache") {
+		return false
+	}
+
+	lifetime, age := e.lifetime(), e.age(now)
+	if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		age += minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+
+	// stale responses accepted by the request
+	respCC := parseCacheControl(e.resp.Header)
+	if !reqCC.has("max-stale") || respCC.has("must-revalidate") || respCC.has("no-cache") {
+		return false
+	}
+	maxStale, _ := reqCC.duration("max-stale")
+	return reqCC["max-stale"] == "" || age-lifetime <= maxStale
+}
+
+// staleness returns how long the response has been stale, negative when fresh
+func (e *cacheEntry) staleness(now time.Time) time.Duration {
+	return e.age(now) - e.lifetime()
+}
+
+// servableStale checks if the response allows serving it stale for up to the
+// duration of the directive of the request or the response
+func (e *cacheEntry) servableStale(directive string, reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(e.resp.Header)
+	if respCC.has("must-revalidate") || respCC.has("no-cache") {
+		return false
+	}
+
+	limit, ok := respCC.duration(directive)
+	if d, reqOK := reqCC.duration(directive); reqOK && (!ok || d > limit) {
+		limit, ok = d, true
+	}
+	return ok && e.staleness(now) <= limit
+}
+
+// staleWhileRevalidate checks if the stale response can be served while it's
+// revalidated in the background, RFC 5861 section 3
+func (e *cacheEntry) staleWhileRevalidate(reqCC cacheControl, now time.Time) bool {
+	return !reqCC.has("no-cache") && e.servableStale("stale-while-revalidate", cacheControl{}, now)
+}
+
+// staleIfError checks if the stale response can be served instead of an
+// error, RFC 5861 section 4
+func (e *cacheEntry) staleIfError(reqCC cacheControl, now time.Time) bool {
+	return e.servableStale("stale-if-error", reqCC, now)
+}
+
+// hasValidators checks if the entry can be revalidated
+func (e *cacheEntry) hasValidators() bool {
+	return e.resp.Header.Get(etagHeaderKey) != "" || e.resp.Header.Get(lastModifiedHeaderKey) != ""
+}
+
+// validators sets the conditional headers revalidating the entry and returns
+// whether it has any validator
+func (e *cacheEntry) validators(h http.Header) bool {
+	if etag := e.resp.Header.Get(etagHeaderKey); etag != "" {
+		h.Set(ifNoneMatchHeaderKey, etag)
+	}
+	if lastModified := e.resp.Header.Get(lastModifiedHeaderKey); lastModified != "" {
+		h.Set(ifModifiedSinceHeaderKey, lastModified)
+	}
+	return e.hasValidators()
+}
+
+// update refreshes the stored headers with the ones of the 304 response
+func (e *cacheEntry) update(notModified *http.Response, requestTime, responseTime time.Time) {
+	for name, values := range notModified.Header {
+		if !cacheUpdateSkippedHeaders[name] {
+			e.resp.Header[name] = values
+		}
+	}
+	e.RequestTime, e.ResponseTime = requestTime, responseTime
+}
+
+// response returns the Response of the entry
+func (e *cacheEntry) response(req *Request, status CacheStatus, now time.Time) *Response {
+	raw := *e.resp
+	raw.Header = e.resp.Header.Clone()
+	raw.Header.Set(ageHeaderKey, strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	raw.Body = ioutil.NopCloser(bytes.NewReader(e.body))
+	raw.Request = req.Request
+
+	return &Response{
+		RawResponse: &raw,
+		DataDump:    &DataDump{ResponseDump: e.Response},
+		CacheStatus: status,
+		requestURL:  req.URL,
+	}
+}
+
+// hasConditionalHeaders checks if the request is conditional on its own
+func hasConditionalHeaders(h http.Header) bool {
+	for _, name := range []string{ifNoneMatchHeaderKey, ifModifiedSinceHeaderKey, "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if h.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestCacheControl returns the Cache-Control directives of the request,
+// falling back to Pragma: no-cache
+func requestCacheControl(h http.Header) cacheControl {
+	cc := parseCacheControl(h)
+	if len(cc) == 0 && strings.EqualFold(strings.TrimSpace(h.Get(pragmaHeaderKey)), "no-cache") {
+		cc["no-cache"] = ""
+	}
+	return cc
+}
+
+// doCached serves the GET requests from the cache, revalidating the stale
+// responses, and performs the others through do. The successful unsafe
+// requests invalidate the response of their URL
+func (c *BaseClient) doCached(req *Request, state *callState) (*Response, error) {
+	if c.cacheStore == nil {
+		return c.do(req, state)
+	}
+
+	key := requestCacheKey(req)
+	if req.Method != http.MethodGet {
+		resp, err := c.do(req, state)
+		if err == nil && !isSafeMethod(req.Method) && resp.RawResponse.StatusCode < http.StatusBadRequest {
+			c.cacheStore.Delete(key)
+		}
+		return resp, err
+	}
+
+	// the conditional requests of the caller get the server response, the
+	// streamed bodies aren't stored
+	if streaming, _ := c.streams(req); streaming || hasConditionalHeaders(req.Header) {
+		return c.do(req, state)
+	}
+
+	logger := c.getLogger()
+	reqCC := requestCacheControl(req.Header)
+
+	// the responses of a caller credential are only shared when explicitly allowed
+	authorized := c.callerCredentials(req)
+
+	var entry *cacheEntry
+	if b, ok := c.cacheStore.Get(key); ok {
+		var err error
+		if entry, err = decodeCacheEntry(b, req.Request); err != nil {
+			logger.WithError(err).Errorf("%s %s cache entry decoding failed", req.Method, req.URL)
+			entry = nil
+		} else if !entry.matches(req.Request) || authorized && !entry.Shared {
+			entry = nil
+		}
+	}
+
+	now := time.Now()
+	if entry != nil && !c.cacheRevalidate && !req.forceRevalidate {
+		if entry.fresh(reqCC, now) {
+			logger.Debugf("%s %s: cache hit", req.Method, req.URL)
+			return entry.response(req, CacheHit, now), nil
+		}
+		if entry.staleWhileRevalidate(reqCC, now) {
+			logger.Debugf("%s %s: serving stale, revalidating in the background", req.Method, req.URL)
+			c.revalidateInBackground(req, key)
+			return entry.response(req, CacheStale, now), nil
+		}
+	}
+
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeoutResponse(req), nil
+	}
+
+	// revalidate the stale response
+	revalidating := entry != nil && entry.validators(req.Header)
+
+	resp, err := c.do(req, state)
+
+	if revalidating {
+		req.Header.Del(ifNoneMatchHeaderKey)
+		req.Header.Del(ifModifiedSinceHeaderKey)
+	}
+
+	// serve the stale response instead of the error
+	if failure := callError(resp, err, state.gaveUp); failure != nil && entry != nil && req.Context().Err() == nil && entry.staleIfError(reqCC, time.Now()) {
+		logger.WithError(failure).Warnf("%s %s failed, serving the stale response", req.Method, req.URL)
+		return c.staleResponse(req, entry, resp, err, failure), nil
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	responseTime := time.Now()
+	requestTime := responseTime
+	if len(resp.Timings) > 0 {
+		requestTime = resp.Timings[len(resp.Timings)-1].Start
+	}
+
+	if revalidating && resp.RawResponse.StatusCode == http.StatusNotModified {
+		logger.Debugf("%s %s: cache revalidated", req.Method, req.URL)
+
+		_ = drainBody(resp.RawResponse.Body, c.drainLimit)
+		entry.update(resp.RawResponse, requestTime, responseTime)
+		c.storeCacheEntry(key, entry)
+
+		cached := entry.response(req, CacheRevalidated, responseTime)
+		resp.RawResponse = cached.RawResponse
+		resp.DataDump.ResponseDump = entry.Response
+		resp.CacheStatus = CacheRevalidated
+		return resp, nil
+	}
+
+	resp.CacheStatus = CacheMiss
+	if entry, ok := newCacheEntry(req, reqCC, resp, requestTime, responseTime); ok && (!authorized || entry.Shared) &&
+		(!c.cacheRevalidate || entry.hasValidators()) {
+		c.storeCacheEntry(key, entry)
+	}
+
+	return resp, nil
+}
+
+// staleResponse returns the stored response replacing the failed call, marked
+// as degraded. It keeps the attempts of the call
+func (c *BaseClient) staleResponse(req *Request, entry *cacheEntry, resp *Response, err, failure error) *Response {
+	stale := entry.response(req, CacheStale, time.Now())
+	stale.Degraded = true
+	stale.DegradedCause = failure
+
+	if resp != nil {
+		// the failed response body is still open when the retry policy accepted it
+		if err == nil && resp.RawResponse != nil {
+			_ = drainBody(resp.RawResponse.Body, c.drainLimit)
+		}
+		stale.RequestID = resp.RequestID
+		stale.Timings = resp.Timings
+		stale.AttemptDumps = resp.AttemptDumps
+		stale.TLS = resp.TLS
+	}
+
+	return stale
+}
+
+// revalidateInBackground revalidates the stored response of the request,
+// once at a time per key. The request context isn't kept, the call outlives it
+func (c *BaseClient) revalidateInBackground(req *Request, key string) {
+	c.cacheMu.Lock()
+	if c.cacheRevalidating[key] {
+		c.cacheMu.Unlock()
+		return
+	}
+	if c.cacheRevalidating == nil {
+		c.cacheRevalidating = make(map[string]bool)
+	}
+	c.cacheRevalidating[key] = true
+	c.cacheMu.Unlock()
+
+	bg := &Request{
+		Request:         req.Request.Clone(context.Background()),
+		skipAuth:        req.skipAuth,
+		forceRevalidate: true,
+		relativeURL:     req.relativeURL,
+		authApplied:     req.authApplied,
+	}
+
+	go func() {
+		defer func() {
+			c.cacheMu.Lock()
+			delete(c.cacheRevalidating, key)
+			c.cacheMu.Unlock()
+		}()
+
+		resp, err := c.Do(bg)
+		if err != nil {
+			c.getLogger().WithError(err).Errorf("%s %s background revalidation failed", bg.Method, bg.URL)
+			return
+		}
+		_ = drainBody(resp.RawResponse.Body, c.drainLimit)
+	}()
+}
+
+// newCacheEntry returns the entry of the response when it can be stored, RFC 9111 section 3
+func newCacheEntry(req *Request, reqCC cacheControl, resp *Response, requestTime, responseTime time.Time) (*cacheEntry, bool) {
+	raw := resp.RawResponse
+	respCC := parseCacheControl(raw.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") || !heuristicallyCacheableStatuses[raw.StatusCode] {
+		return nil, false
+	}
+
+	dumped, body, err := readResponseDump(resp.DataDump.ResponseDump, req.Request)
+	if err != nil {
+		return nil, false
+	}
+
+	e := &cacheEntry{
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Shared:       respCC.has("public") || respCC.has("s-maxage") || respCC.has("must-revalidate"),
+		resp:         dumped,
+		body:         body,
+	}
+
+	for _, v := range raw.Header.Values(varyHeaderKey) {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name == "" {
+				continue
+			}
+			if e.Vary == nil {
+				e.Vary = make(map[string]string)
+			}
+			e.Vary[name] = strings.Join(req.Header.Values(name), ", ")
+		}
+	}
+
+	// a response which can't be served nor revalidated isn't worth storing
+	if e.lifetime() == 0 && !e.hasValidators() {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// storeCacheEntry stores the entry
+func (c *BaseClient) storeCacheEntry(key string, e *cacheEntry) {
+	b, err := e.encode()
+	if err != nil {
+		c.getLogger().WithError(err).Errorf("cache entry encoding failed")
+		return
+	}
+	c.cacheStore.Set(key, b)
+}
+
+// gatewayTimeoutResponse returns the 504 response of an only-if-cached request
+// without a cached response, RFC 9111 section 5.2.1.7
+func gatewayTimeoutResponse(req *Request) *Response {
+	resp := NewSyntheticResponse(req, http.StatusGatewayTimeout, nil, nil)
+	resp.CacheStatus = CacheMiss
+	return resp
+}
+
+// callError returns the error of the call, a failed status or a response the
+// retries gave up on, e.g. a 429, counts as an error
+func callError(resp *Response, err error, gaveUp bool) error {
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.RawResponse != nil && (gaveUp || staleErrorStatuses[resp.RawResponse.StatusCode]) {
+		return fmt.Errorf("unexpected HTTP status %s", resp.RawResponse.Status)
+	}
+	return nil
+}
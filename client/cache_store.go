@@ -0,0 +1,143 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore stores the cached responses, the keys are the request URLs.
+// The implementations must be safe for concurrent use; a store failing to
+// read an entry reports a miss
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// memoryCacheItem is an entry of the MemoryCacheStore
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+// MemoryCacheStore is an in-memory CacheStore bounded by the total size of
+// its entries, the least recently used entries are evicted first
+type MemoryCacheStore struct {
+	maxSize int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// NewMemoryCacheStore creates a new MemoryCacheStore holding up to maxSize bytes
+func NewMemoryCacheStore(maxSize int64) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry of the key and marks it as recently used
+func (s *MemoryCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(e)
+	return e.Value.(*memoryCacheItem).value, true
+}
+
+// Set stores the entry, evicting the least recently used entries to make room.
+// An entry larger than the store isn't stored
+func (s *MemoryCacheStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	if int64(len(value)) > s.maxSize {
+		return
+	}
+
+	s.items[key] = s.lru.PushFront(&memoryCacheItem{key: key, value: value})
+	s.size += int64(len(value))
+
+	for s.size > s.maxSize {
+		s.remove(s.lru.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete removes the entry of the key
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+}
+
+// Size returns the total size of the entries
+func (s *MemoryCacheStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// remove removes the entry of the key, the lock must be held
+func (s *MemoryCacheStore) remove(key string) {
+	e, ok := s.items[key]
+	if !ok {
+		return
+	}
+	s.lru.Remove(e)
+	delete(s.items, key)
+	s.size -= int64(len(e.Value.(*memoryCacheItem).value))
+}
+
+// DiskCacheStore is a CacheStore keeping every entry in a file of its directory
+type DiskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore creates a new DiskCacheStore in the directory, created when missing
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStore{dir: dir}, nil
+}
+
+// Get returns the entry of the key
+func (s *DiskCacheStore) Get(key string) ([]byte, bool) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Set stores the entry, the file is replaced atomically
+func (s *DiskCacheStore) Set(key string, value []byte) {
+	_ = writeFileAtomic(s.path(key), value, 0600)
+}
+
+// Delete removes the entry of the key
+func (s *DiskCacheStore) Delete(key string) {
+	_ = os.Remove(s.path(key))
+}
+
+// path returns the file of the key
+func (s *DiskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,73 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheStore(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryCacheStore(10)
+	s.Set("a", []byte("1234"))
+	s.Set("b", []byte("1234"))
+	assert.Equal(t, int64(8), s.Size())
+
+	// a is the most recently used, b is evicted
+	_, ok := s.Get("a")
+	assert.True(t, ok)
+	s.Set("c", []byte("1234"))
+
+	_, ok = s.Get("b")
+	assert.False(t, ok)
+	v, ok := s.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1234"), v)
+	assert.Equal(t, int64(8), s.Size())
+
+	// replaced
+	s.Set("a", []byte("12"))
+	assert.Equal(t, int64(6), s.Size())
+
+	// too large
+	s.Set("d", []byte("12345678901"))
+	_, ok = s.Get("d")
+	assert.False(t, ok)
+
+	s.Delete("a")
+	s.Delete("missing")
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(4), s.Size())
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	s, err := NewDiskCacheStore(dir)
+	assert.Nil(t, err)
+
+	_, ok := s.Get("https://api.local/a")
+	assert.False(t, ok)
+
+	s.Set("https://api.local/a", []byte("entry"))
+	v, ok := s.Get("https://api.local/a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("entry"), v)
+
+	// persisted
+	reopened, err := NewDiskCacheStore(dir)
+	assert.Nil(t, err)
+	_, ok = reopened.Get("https://api.local/a")
+	assert.True(t, ok)
+
+	s.Delete("https://api.local/a")
+	_, ok = reopened.Get("https://api.local/a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,541 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseCacheControl(t *testing.T) {
+	t.Parallel()
+
+	cc := parseCacheControl(http.Header{cacheControlHeaderKey: {`Max-Age=60, no-cache, private="Set-Cookie"`, "max-stale"}})
+	assert.Equal(t, cacheControl{"max-age": "60", "no-cache": "", "private": "Set-Cookie", "max-stale": ""}, cc)
+
+	d, ok := cc.duration("max-age")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	_, ok = cc.duration("s-maxage")
+	assert.False(t, ok)
+
+	cc = requestCacheControl(http.Header{pragmaHeaderKey: {"no-cache"}})
+	assert.True(t, cc.has("no-cache"))
+}
+
+func Test_freshnessLifetime(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	resp := func(status int, h http.Header) *http.Response {
+		return &http.Response{StatusCode: status, Header: h}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		resp     *http.Response
+		lifetime time.Duration
+	}{
+		{"max-age", resp(http.StatusOK, http.Header{cacheControlHeaderKey: {"max-age=3600"}, expiresHeaderKey: {"0"}}), time.Hour},
+		{"no-cache", resp(http.StatusOK, http.Header{cacheControlHeaderKey: {"no-cache, max-age=3600"}}), 0},
+		{"expires", resp(http.StatusOK, http.Header{expiresHeaderKey: {date.Add(time.Minute).Format(http.TimeFormat)}}), time.Minute},
+		{"invalid expires", resp(http.StatusOK, http.Header{expiresHeaderKey: {"0"}}), 0},
+		{"heuristic", resp(http.StatusOK, http.Header{lastModifiedHeaderKey: {date.Add(-10 * time.Hour).Format(http.TimeFormat)}}), time.Hour},
+		{"heuristic cap", resp(http.StatusOK, http.Header{lastModifiedHeaderKey: {date.Add(-1000 * time.Hour).Format(http.TimeFormat)}}), cacheHeuristicMaxLifetime},
+		{"heuristic status", resp(http.StatusCreated, http.Header{lastModifiedHeaderKey: {date.Add(-10 * time.Hour).Format(http.TimeFormat)}}), 0},
+		{"none", resp(http.StatusOK, http.Header{}), 0},
+	} {
+		assert.Equal(t, tc.lifetime, freshnessLifetime(tc.resp, date), tc.name)
+	}
+}
+
+func Test_cacheEntry_age(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	e := &cacheEntry{
+		RequestTime:  date.Add(-time.Second),
+		ResponseTime: date.Add(time.Second),
+		resp:         &http.Response{Header: http.Header{dateHeaderKey: {date.Format(http.TimeFormat)}, ageHeaderKey: {"10"}}},
+	}
+
+	// the age value corrected by the response delay wins over the apparent age
+	assert.Equal(t, 12*time.Second, e.age(date.Add(time.Second)))
+	assert.Equal(t, 72*time.Second, e.age(date.Add(time.Minute+time.Second)))
+}
+
+func Test_cacheEntry_fresh(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	entry := func(cc string) *cacheEntry {
+		return &cacheEntry{
+			RequestTime:  now.Add(-time.Minute),
+			ResponseTime: now.Add(-time.Minute),
+			resp:         &http.Response{StatusCode: http.StatusOK, Header: http.Header{cacheControlHeaderKey: {cc}}},
+		}
+	}
+	req := func(cc string) cacheControl {
+		return parseCacheControl(http.Header{cacheControlHeaderKey: {cc}})
+	}
+
+	assert.True(t, entry("max-age=120").fresh(req(""), now))
+	assert.False(t, entry("max-age=30").fresh(req(""), now))
+	assert.False(t, entry("max-age=120").fresh(req("no-cache"), now))
+	assert.False(t, entry("max-age=120").fresh(req("max-age=30"), now))
+	assert.False(t, entry("max-age=120").fresh(req("min-fresh=90"), now))
+	assert.True(t, entry("max-age=30").fresh(req("max-stale"), now))
+	assert.True(t, entry("max-age=30").fresh(req("max-stale=40"), now))
+	assert.False(t, entry("max-age=30").fresh(req("max-stale=10"), now))
+	assert.False(t, entry("max-age=30, must-revalidate").fresh(req("max-stale"), now))
+}
+
+func TestBaseClient_WithCache(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls, revalidations int32
+	mux.HandleFunc("/fresh", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+		_, _ = w.Write([]byte("fresh"))
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "no-cache")
+		w.Header().Set(etagHeaderKey, `"v1"`)
+		if r.Header.Get(ifNoneMatchHeaderKey) == `"v1"` {
+			atomic.AddInt32(&revalidations, 1)
+			w.Header().Set("X-Revalidated", "true")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("etag"))
+	})
+	mux.HandleFunc("/vary", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+		w.Header().Set(varyHeaderKey, acceptHeaderKey)
+		_, _ = w.Write([]byte(r.Header.Get(acceptHeaderKey)))
+	})
+	mux.HandleFunc("/no-store", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "no-store, max-age=3600")
+	})
+
+	c := NewClient(logrus.New()).WithCache(NewMemoryCacheStore(1 << 20))
+
+	get := func(path string, header http.Header) *Response {
+		req, err := c.NewRequest(context.Background(), http.MethodGet, u+path, nil)
+		assert.Nil(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := c.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+	calls0 := func() int32 {
+		return atomic.SwapInt32(&calls, 0)
+	}
+
+	// fresh
+	resp := get("/fresh", nil)
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	resp = get("/fresh", nil)
+	assert.Equal(t, CacheHit, resp.CacheStatus)
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "fresh", body)
+	assert.NotEmpty(t, resp.GetHeaders().Get(ageHeaderKey))
+	assert.Empty(t, resp.Timings)
+	assert.Equal(t, int32(1), calls0())
+
+	// the request forces the revalidation, without validators it's a full request
+	resp = get("/fresh", http.Header{cacheControlHeaderKey: {"no-cache"}})
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	assert.Equal(t, int32(1), calls0())
+
+	// revalidated with the ETag
+	resp = get("/etag", nil)
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	for i := 0; i < 2; i++ {
+		resp = get("/etag", nil)
+		assert.Equal(t, CacheRevalidated, resp.CacheStatus)
+		assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+		assert.Equal(t, "true", resp.GetHeaders().Get("X-Revalidated"))
+		assert.Len(t, resp.Timings, 1)
+		body, _ = resp.GetStringBody()
+		assert.Equal(t, "etag", body)
+	}
+	assert.Equal(t, int32(3), calls0())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&revalidations))
+
+	// the conditional requests of the caller get the server response
+	resp = get("/etag", http.Header{ifNoneMatchHeaderKey: {`"v1"`}})
+	assert.Equal(t, http.StatusNotModified, resp.GetStatusCode())
+	assert.Empty(t, resp.CacheStatus)
+	calls0()
+
+	// vary
+	get("/vary", http.Header{acceptHeaderKey: {"text/plain"}})
+	resp = get("/vary", http.Header{acceptHeaderKey: {"text/plain"}})
+	assert.Equal(t, CacheHit, resp.CacheStatus)
+	resp = get("/vary", http.Header{acceptHeaderKey: {"text/html"}})
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	body, _ = resp.GetStringBody()
+	assert.Equal(t, "text/html", body)
+	assert.Equal(t, int32(2), calls0())
+
+	// no-store
+	get("/no-store", nil)
+	resp = get("/no-store", nil)
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	assert.Equal(t, int32(2), calls0())
+
+	// only-if-cached
+	resp = get("/fresh", http.Header{cacheControlHeaderKey: {"only-if-cached"}})
+	assert.Equal(t, CacheHit, resp.CacheStatus)
+	resp = get("/missing", http.Header{cacheControlHeaderKey: {"only-if-cached"}})
+	assert.Equal(t, http.StatusGatewayTimeout, resp.GetStatusCode())
+	assert.Equal(t, int32(0), calls0())
+
+	// the unsafe requests invalidate the URL
+	_, err := c.Post(context.Background(), u+"/fresh", jsonContentType, nil)
+	assert.Nil(t, err)
+	resp = get("/fresh", nil)
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	assert.Equal(t, int32(2), calls0())
+}
+
+// cacheUserKey is the context key of the user of the cache tests
+type cacheUserKey struct{}
+
+func TestBaseClient_WithCache_callerCredentials(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+		_, _ = w.Write([]byte(r.Header.Get(authorizationHeaderKey)))
+	})
+	mux.HandleFunc("/catalog", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "public, max-age=3600")
+		_, _ = w.Write([]byte("catalog"))
+	})
+
+	c := NewClient(logrus.New()).
+		WithCache(NewMemoryCacheStore(1 << 20)).
+		WithCredentialProvider(CredentialProviderFunc(func(ctx context.Context, _ *url.URL) (*Credential, error) {
+			return &Credential{Scheme: bearerAuthScheme, Token: ctx.Value(cacheUserKey{}).(string)}, nil
+		}))
+
+	get := func(path, user string) *Response {
+		resp, err := c.Get(context.WithValue(context.Background(), cacheUserKey{}, user), u+path)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	// every user gets its own response
+	for _, user := range []string{"alice", "bob", "alice"} {
+		body, _ := get("/me", user).GetStringBody()
+		assert.Equal(t, "Bearer "+user, body)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// the public responses are shared
+	assert.Equal(t, CacheMiss, get("/catalog", "alice").CacheStatus)
+	assert.Equal(t, CacheHit, get("/catalog", "bob").CacheStatus)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithCache_endpointPool(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+		_, _ = w.Write([]byte("items"))
+	}
+	mux1, u1, shutdown1 := setup()
+	defer shutdown1()
+	mux1.HandleFunc("/", handler)
+	mux2, u2, shutdown2 := setup()
+	defer shutdown2()
+	mux2.HandleFunc("/", handler)
+
+	pool, err := NewEndpointPool(RoundRobinStrategy(), u1+"/", u2+"/")
+	assert.Nil(t, err)
+	c := NewClient(logrus.New()).WithCache(NewMemoryCacheStore(1 << 20)).WithEndpointPool(pool)
+
+	// the endpoints share the entry of the relative URL
+	for _, status := range []CacheStatus{CacheMiss, CacheHit, CacheHit} {
+		resp, err := c.Get(context.Background(), "items")
+		assert.Nil(t, err)
+		assert.Equal(t, status, resp.CacheStatus)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithCache_disk(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=60")
+		_, _ = w.Write([]byte(strconv.Itoa(int(n))))
+	})
+
+	store, err := NewDiskCacheStore(t.TempDir())
+	assert.Nil(t, err)
+
+	// the entries survive the client
+	for i := 0; i < 2; i++ {
+		resp, err := NewClient(logrus.New()).WithCache(store).Get(context.Background(), u)
+		assert.Nil(t, err)
+		body, _ := resp.GetStringBody()
+		assert.Equal(t, "1", body)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var mu sync.Mutex
+	version := 1
+	var conditional []string
+	mux.HandleFunc("/document", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		conditional = append(conditional, r.Header.Get(ifNoneMatchHeaderKey)+"|"+r.Header.Get(ifModifiedSinceHeaderKey))
+
+		etag := `"v` + strconv.Itoa(version) + `"`
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+		w.Header().Set(etagHeaderKey, etag)
+		w.Header().Set(lastModifiedHeaderKey, "Thu, 01 Oct 2026 12:00:00 GMT")
+		if r.Header.Get(ifNoneMatchHeaderKey) == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		_, _ = w.Write([]byte(`{"version":` + strconv.Itoa(version) + `}`))
+	})
+	mux.HandleFunc("/no-validators", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		conditional = append(conditional, r.Header.Get(ifNoneMatchHeaderKey)+"|"+r.Header.Get(ifModifiedSinceHeaderKey))
+		w.Header().Set(cacheControlHeaderKey, "max-age=3600")
+	})
+
+	c := NewClient(logrus.New()).WithConditionalRequests(NewMemoryCacheStore(1 << 20))
+
+	var doc struct {
+		Version int `json:"version"`
+	}
+
+	resp, err := c.Get(context.Background(), u+"/document")
+	assert.Nil(t, err)
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	assert.False(t, resp.NotModified)
+
+	// revalidated although fresh
+	resp, err = c.Get(context.Background(), u+"/document")
+	assert.Nil(t, err)
+	assert.Equal(t, CacheRevalidated, resp.CacheStatus)
+	assert.True(t, resp.NotModified)
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	assert.Nil(t, resp.UnmarshalJSONResponse(&doc))
+	assert.Equal(t, 1, doc.Version)
+
+	// changed
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+	for i := 0; i < 2; i++ {
+		resp, err = c.Get(context.Background(), u+"/document")
+		assert.Nil(t, err)
+		assert.Nil(t, resp.UnmarshalJSONResponse(&doc))
+		assert.Equal(t, 2, doc.Version)
+	}
+	assert.Equal(t, CacheRevalidated, resp.CacheStatus)
+
+	// nothing to revalidate
+	for i := 0; i < 2; i++ {
+		resp, err = c.Get(context.Background(), u+"/no-validators")
+		assert.Nil(t, err)
+		assert.Equal(t, CacheMiss, resp.CacheStatus)
+	}
+
+	mu.Lock()
+	lastModified := "Thu, 01 Oct 2026 12:00:00 GMT"
+	assert.Equal(t, []string{
+		"|",
+		`"v1"|` + lastModified,
+		`"v1"|` + lastModified,
+		`"v2"|` + lastModified,
+		"|",
+		"|",
+	}, conditional)
+	mu.Unlock()
+
+	// a 304 answering the caller's own conditional request has no body
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u+"/document", nil)
+	assert.Nil(t, err)
+	req.Header.Set(ifNoneMatchHeaderKey, `"v2"`)
+	resp, err = c.Do(req)
+	assert.Nil(t, err)
+	assert.True(t, resp.NotModified)
+	assert.Equal(t, http.StatusNotModified, resp.GetStatusCode())
+	assert.Equal(t, ErrNotModified, resp.UnmarshalJSONResponse(&doc))
+}
+
+func TestBaseClient_WithCache_staleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=60, stale-while-revalidate=600")
+		if n == 1 {
+			// stale on arrival
+			w.Header().Set(ageHeaderKey, "120")
+		}
+		_, _ = w.Write([]byte("v" + strconv.Itoa(int(n))))
+	})
+
+	c := NewClient(logrus.New()).WithCache(NewMemoryCacheStore(1 << 20))
+
+	get := func() (*Response, string) {
+		resp, err := c.Get(context.Background(), u)
+		assert.Nil(t, err)
+		body, _ := resp.GetStringBody()
+		return resp, body
+	}
+
+	resp, body := get()
+	assert.Equal(t, CacheMiss, resp.CacheStatus)
+	assert.Equal(t, "v1", body)
+
+	resp, body = get()
+	assert.Equal(t, CacheStale, resp.CacheStatus)
+	assert.False(t, resp.Degraded)
+	assert.Equal(t, "v1", body)
+
+	// the background revalidation stored the new response
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, body = get()
+		if resp.CacheStatus == CacheHit || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, CacheHit, resp.CacheStatus)
+	assert.Equal(t, "v2", body)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithCache_staleIfError(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var mu sync.Mutex
+	failing := map[string]bool{}
+	handler := func(cc string, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if failing[r.URL.Path] {
+				w.WriteHeader(status)
+				return
+			}
+			failing[r.URL.Path] = true
+			w.Header().Set(cacheControlHeaderKey, cc)
+			w.Header().Set(ageHeaderKey, "120")
+			_, _ = w.Write([]byte("last good"))
+		}
+	}
+	mux.HandleFunc("/response", handler("max-age=60, stale-if-error=600", http.StatusServiceUnavailable))
+	mux.HandleFunc("/request", handler("max-age=60", http.StatusServiceUnavailable))
+	mux.HandleFunc("/expired", handler("max-age=60, stale-if-error=30", http.StatusServiceUnavailable))
+	mux.HandleFunc("/must-revalidate", handler("max-age=60, stale-if-error=600, must-revalidate", http.StatusServiceUnavailable))
+	mux.HandleFunc("/throttled", handler("max-age=60, stale-if-error=600", http.StatusTooManyRequests))
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithCache(NewMemoryCacheStore(1 << 20))
+
+	get := func(path string, cc string) (*Response, error) {
+		req, err := c.NewRequest(context.Background(), http.MethodGet, u+path, nil)
+		assert.Nil(t, err)
+		if cc != "" {
+			req.Header.Set(cacheControlHeaderKey, cc)
+		}
+		return c.Do(req)
+	}
+
+	for _, tc := range []struct {
+		path  string
+		cc    string
+		stale bool
+		cause string
+	}{
+		{"/response", "", true, "503"},
+		{"/request", "stale-if-error=600", true, "503"},
+		{"/expired", "", false, ""},
+		{"/must-revalidate", "", false, ""},
+		// the retries gave up on the 429
+		{"/throttled", "", true, "429"},
+	} {
+		_, err := get(tc.path, tc.cc)
+		assert.Nil(t, err)
+
+		resp, err := get(tc.path, tc.cc)
+		if !tc.stale {
+			assert.NotNil(t, err, tc.path)
+			continue
+		}
+
+		assert.Nil(t, err, tc.path)
+		assert.Equal(t, CacheStale, resp.CacheStatus)
+		assert.True(t, resp.Degraded)
+		assert.Contains(t, resp.DegradedCause.Error(), tc.cause)
+		assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+		assert.Len(t, resp.Timings, 1)
+		body, _ := resp.GetStringBody()
+		assert.Equal(t, "last good", body)
+	}
+}
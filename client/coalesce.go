@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalescer shares a single call between the identical GETs in flight
+type coalescer struct {
+	headers []string
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a shared call and its callers
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	resp *Response
+	body []byte
+	err  error
+}
+
+// WithRequestCoalescing shares a single call between the identical GET and
+// HEAD requests in flight, and returns the BaseClient. The requests are
+// identical when their method, URL and values of the headers match. Every
+// caller gets its own copy of the buffered response; a caller giving up
+// doesn't cancel the call while others still wait for it. The requests with a
+// credential set by the caller or by a provider aren't shared
+func (c *BaseClient) WithRequestCoalescing(headers ...string) *BaseClient {
+	co := &coalescer{flights: make(map[string]*flight)}
+	for _, h := range headers {
+		co.headers = append(co.headers, http.CanonicalHeaderKey(h))
+	}
+	c.coalescer = co
+	return c
+}
+
+// coalescable checks if the request can share its call. A streamed body can't
+// be shared, neither can the response of a credential which may be the caller's
+func (c *BaseClient) coalescable(req *Request) bool {
+	if streaming, _ := c.streams(req); streaming || c.callerCredentials(req) {
+		return false
+	}
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// key returns the key of the identical requests
+func (co *coalescer) key(req *Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.String())
+	for _, h := range co.headers {
+		b.WriteByte('\n')
+		b.WriteString(h)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(req.Header.Values(h), ", "))
+	}
+	return b.String()
+}
+
+// coalesce joins the call in flight of the identical requests, or starts it,
+// and waits for its result or the cancellation of the request
+func (c *BaseClient) coalesce(req *Request) (*Response, error) {
+	co := c.coalescer
+	key := co.key(req)
+
+	co.mu.Lock()
+	f, joined := co.flights[key]
+	if joined {
+		f.waiters++
+	} else {
+		// the call outlives the request of its leader
+		ctx, cancel := context.WithCancel(detachedContext{req.Context()})
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		co.flights[key] = f
+
+		shared := *req
+		shared.Request = req.Request.Clone(ctx)
+		go c.fly(key, f, &shared)
+	}
+	co.mu.Unlock()
+
+	if joined {
+		c.getLogger().Debugf("%s %s: joining the identical call in flight", req.Method, req.URL)
+	}
+
+	select {
+	case <-f.done:
+		return f.result(joined)
+	case <-req.Context().Done():
+		co.leave(key, f)
+		return nil, req.Context().Err()
+	}
+}
+
+// fly performs the shared call and buffers its body
+func (c *BaseClient) fly(key string, f *flight, req *Request) {
+	f.resp, f.err = c.call(req)
+	if f.err == nil && f.resp != nil && f.resp.RawResponse != nil {
+		var err error
+		if f.body, err = readAndClose(f.resp.RawResponse.Body); err != nil {
+			f.resp, f.err = nil, err
+		}
+	}
+
+	co := c.coalescer
+	co.mu.Lock()
+	if co.flights[key] == f {
+		delete(co.flights, key)
+	}
+	co.mu.Unlock()
+
+	f.cancel()
+	close(f.done)
+}
+
+// leave removes a caller giving up, the call is canceled when it was the last one
+func (co *coalescer) leave(key string, f *flight) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+
+	// the next identical request starts a new call
+	if co.flights[key] == f {
+		delete(co.flights, key)
+	}
+	f.cancel()
+}
+
+// result returns a copy of the response of the call
+func (f *flight) result(coalesced bool) (*Response, error) {
+	if f.resp == nil {
+		return nil, f.err
+	}
+
+	resp := *f.resp
+	resp.Coalesced = coalesced
+	if f.resp.RawResponse != nil {
+		raw := *f.resp.RawResponse
+		raw.Header = f.resp.RawResponse.Header.Clone()
+		if f.err == nil {
+			raw.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+		}
+		resp.RawResponse = &raw
+	}
+
+	return &resp, f.err
+}
+
+// detachedContext keeps the values of its parent but not its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
@@ -0,0 +1,227 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitWaiters waits until the call in flight of the request has the number of callers
+func waitWaiters(t *testing.T, c *BaseClient, req *Request, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.coalescer.mu.Lock()
+		f, ok := c.coalescer.flights[c.coalescer.key(req)]
+		waiters := 0
+		if ok {
+			waiters = f.waiters
+		}
+		c.coalescer.mu.Unlock()
+
+		if waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("the call in flight doesn't have %d callers", n)
+}
+
+func TestBaseClient_WithRequestCoalescing(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	release := make(chan struct{})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		_, _ = w.Write([]byte(r.Header.Get("X-Tenant")))
+	})
+
+	c := NewClient(logrus.New()).WithRequestCoalescing("x-tenant")
+
+	newRequest := func(tenant string) *Request {
+		req, err := c.NewRequest(context.Background(), http.MethodGet, u, nil)
+		assert.Nil(t, err)
+		req.Header.Set("X-Tenant", tenant)
+		return req
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	responses := make([]*Response, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			tenant := "a"
+			if i == 0 {
+				tenant = "b"
+			}
+			resp, err := c.Do(newRequest(tenant))
+			assert.Nil(t, err)
+			responses[i] = resp
+		}(i)
+	}
+	waitWaiters(t, c, newRequest("a"), callers-1)
+	waitWaiters(t, c, newRequest("b"), 1)
+	close(release)
+	wg.Wait()
+
+	// one call per tenant
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	var coalesced int
+	for i, resp := range responses {
+		if resp.Coalesced {
+			coalesced++
+		}
+		body, err := resp.GetStringBody()
+		assert.Nil(t, err)
+		if i == 0 {
+			assert.Equal(t, "b", body)
+		} else {
+			assert.Equal(t, "a", body)
+		}
+	}
+	assert.Equal(t, callers-2, coalesced)
+
+	// the responses are independent
+	responses[1].GetHeaders().Set("X-Mutated", "true")
+	assert.Empty(t, responses[2].GetHeaders().Get("X-Mutated"))
+
+	// not in flight anymore
+	_, err := c.Do(newRequest("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithRequestCoalescing_cancel(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	release := make(chan struct{})
+	arrived := make(chan struct{})
+	canceled := make(chan struct{})
+	mux.HandleFunc("/shared", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("shared"))
+	})
+	mux.HandleFunc("/abandoned", func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-r.Context().Done()
+		close(canceled)
+	})
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithRequestCoalescing()
+
+	// the leader gives up, the follower still gets the response
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader, err := c.NewRequest(leaderCtx, http.MethodGet, u+"/shared", nil)
+	assert.Nil(t, err)
+
+	leaderErr := make(chan error)
+	go func() {
+		_, err := c.Do(leader)
+		leaderErr <- err
+	}()
+	waitWaiters(t, c, leader, 1)
+
+	follower := make(chan *Response)
+	go func() {
+		resp, err := c.Get(context.Background(), u+"/shared")
+		assert.Nil(t, err)
+		follower <- resp
+	}()
+	waitWaiters(t, c, leader, 2)
+
+	cancelLeader()
+	assert.True(t, errors.Is(<-leaderErr, context.Canceled))
+
+	close(release)
+	resp := <-follower
+	assert.True(t, resp.Coalesced)
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "shared", body)
+
+	// every caller gives up, the call is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, u+"/abandoned")
+		done <- err
+	}()
+	req, _ := c.NewRequest(ctx, http.MethodGet, u+"/abandoned", nil)
+	waitWaiters(t, c, req, 1)
+	<-arrived
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the abandoned call wasn't canceled")
+	}
+}
+
+func TestBaseClient_WithRequestCoalescing_callerCredentials(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	release := make(chan struct{})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		_, _ = w.Write([]byte(r.Header.Get(authorizationHeaderKey)))
+	})
+
+	// the credential comes from the context of the caller
+	c := NewClient(logrus.New()).
+		WithRequestCoalescing().
+		WithCredentialProvider(CredentialProviderFunc(func(ctx context.Context, _ *url.URL) (*Credential, error) {
+			return &Credential{Scheme: bearerAuthScheme, Token: ctx.Value(cacheUserKey{}).(string)}, nil
+		}))
+
+	users := []string{"alice", "bob"}
+	var wg sync.WaitGroup
+	bodies := make([]string, len(users))
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+
+			resp, err := c.Get(context.WithValue(context.Background(), cacheUserKey{}, user), u)
+			assert.Nil(t, err)
+			bodies[i], _ = resp.GetStringBody()
+		}(i, user)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, []string{"Bearer alice", "Bearer bob"}, bodies)
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Content codings
+const (
+	gzipEncoding     string = "gzip"
+	deflateEncoding  string = "deflate"
+	identityEncoding string = "identity"
+)
+
+// ErrDecodedBodyTooLarge is returned when reading a body which decompresses
+// beyond the limit set by WithResponseDecompression
+var ErrDecodedBodyTooLarge = errors.New("decoded body exceeds the limit")
+
+// ContentDecoder returns a reader decoding a content coding of the body
+type ContentDecoder func(r io.Reader) (io.ReadCloser, error)
+
+// contentDecoders is the registry of the content decoders, in the order
+// they are advertised in Accept-Encoding
+var contentDecoders = struct {
+	sync.RWMutex
+	names    []string
+	decoders map[string]ContentDecoder
+}{
+	names: []string{gzipEncoding, deflateEncoding},
+	decoders: map[string]ContentDecoder{
+		gzipEncoding:    newGzipDecoder,
+		deflateEncoding: newDeflateDecoder,
+	},
+}
+
+// RegisterContentDecoder registers the decoder of the content coding, e.g.
+// "br" or "zstd", for every client. Registering a coding again replaces its decoder
+func RegisterContentDecoder(encoding string, d ContentDecoder) {
+	encoding = strings.ToLower(encoding)
+
+	contentDecoders.Lock()
+	defer contentDecoders.Unlock()
+
+	if _, ok := contentDecoders.decoders[encoding]; !ok {
+		contentDecoders.names = append(contentDecoders.names, encoding)
+	}
+	contentDecoders.decoders[encoding] = d
+}
+
+// contentDecoder returns the decoder of the content coding
+func contentDecoder(encoding string) (ContentDecoder, bool) {
+	contentDecoders.RLock()
+	defer contentDecoders.RUnlock()
+
+	d, ok := contentDecoders.decoders[encoding]
+	return d, ok
+}
+
+// acceptEncoding returns the Accept-Encoding value of the registered decoders
+func acceptEncoding() string {
+	contentDecoders.RLock()
+	defer contentDecoders.RUnlock()
+
+	return strings.Join(contentDecoders.names, ", ")
+}
+
+// newGzipDecoder returns a gzip decoder
+func newGzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// newDeflateDecoder returns a deflate decoder. The deflate coding is the
+// zlib format, but some servers send raw deflate data
+func newDeflateDecoder(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// WithResponseDecompression advertises the registered content codings in
+// Accept-Encoding and decodes the response bodies, and returns the BaseClient.
+// Reading a body decoding beyond maxDecodedSize bytes fails with
+// ErrDecodedBodyTooLarge, zero disables the limit
+func (c *BaseClient) WithResponseDecompression(maxDecodedSize int64) *BaseClient {
+	c.decompression = true
+	c.maxDecodedSize = maxDecodedSize
+	return c
+}
+
+// WithRequestCompression gzip-compresses the request bodies larger than the
+// threshold, and returns the BaseClient. The compressed body is kept for the retries
+func (c *BaseClient) WithRequestCompression(threshold int64) *BaseClient {
+	c.compressionThreshold = threshold
+	return c
+}
+
+// negotiateEncoding sets the Accept-Encoding of the request, unless set by the caller
+func (c *BaseClient) negotiateEncoding(req *Request) {
+	if c.decompression && req.Header.Get(acceptEncodingHeaderKey) == "" {
+		req.Header.Set(acceptEncodingHeaderKey, acceptEncoding())
+	}
+}
+
+// compressRequest gzip-compresses the body of the request when larger than
+// the threshold. The body is buffered, so it can be rewound on retries
+func (c *BaseClient) compressRequest(req *Request) error {
+	if c.compressionThreshold <= 0 || req.body == nil || req.Header.Get(contentEncodingHeaderKey) != "" {
+		return nil
+	}
+
+	if s, ok := req.body.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	b, err := ioutil.ReadAll(req.body)
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) <= c.compressionThreshold {
+		req.body = bytes.NewReader(b)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req.body = bytes.NewReader(buf.Bytes())
+	req.contentLength = int64(buf.Len())
+	req.plainBody = b
+	req.Header.Set(contentEncodingHeaderKey, gzipEncoding)
+	return nil
+}
+
+// uncompressed returns a copy of the request with the body it had before the
+// compression, so the dumps and the curl commands can be redacted and
+// replayed, or the request itself when its body isn't compressed
+func (r *Request) uncompressed() *Request {
+	if r.plainBody == nil {
+		return r
+	}
+
+	httpReq := *r.Request
+	httpReq.Header = r.Header.Clone()
+	httpReq.Header.Del(contentEncodingHeaderKey)
+	httpReq.ContentLength = int64(len(r.plainBody))
+	httpReq.Body = ioutil.NopCloser(bytes.NewReader(r.plainBody))
+	httpReq.GetBody = nil
+
+	clone := *r
+	clone.Request = &httpReq
+	clone.body = bytes.NewReader(r.plainBody)
+	clone.contentLength = int64(len(r.plainBody))
+	clone.plainBody = nil
+	return &clone
+}
+
+// decodeResponse replaces the body of the response by its decoded form when
+// every content coding has a decoder. The decoding happens while reading
+func (c *BaseClient) decodeResponse(resp *http.Response) {
+	if !c.decompression || bodyless(resp) {
+		return
+	}
+
+	var decoders []ContentDecoder
+	for _, v := range resp.Header.Values(contentEncodingHeaderKey) {
+		for _, encoding := range strings.Split(v, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding == "" || encoding == identityEncoding {
+				continue
+			}
+			d, ok := contentDecoder(encoding)
+			if !ok {
+				return
+			}
+			decoders = append(decoders, d)
+		}
+	}
+	if len(decoders) == 0 {
+		return
+	}
+
+	resp.Body = &decodingReader{body: resp.Body, decoders: decoders, limit: c.maxDecodedSize}
+	resp.Header.Del(contentEncodingHeaderKey)
+	resp.Header.Del(contentLengthHeaderKey)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReader decodes the body on the fly, the decoders are created on the
+// first read so an empty body isn't an error
+type decodingReader struct {
+	body     io.ReadCloser
+	decoders []ContentDecoder
+	limit    int64
+
+	r       io.Reader
+	closers []io.Closer
+	read    int64
+	err     error
+}
+
+// Read reads the decoded body
+func (d *decodingReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.r == nil {
+		if d.err = d.init(); d.err != nil {
+			return 0, d.err
+		}
+	}
+
+	n, err := d.r.Read(p)
+	d.read += int64(n)
+	if d.limit > 0 && d.read > d.limit {
+		d.err = ErrDecodedBodyTooLarge
+		return n - int(d.read-d.limit), d.err
+	}
+	return n, err
+}
+
+// init chains the decoders, the last applied coding is decoded first
+func (d *decodingReader) init() error {
+	r := io.Reader(d.body)
+	for i := len(d.decoders) - 1; i >= 0; i-- {
+		rc, err := d.decoders[i](r)
+		if errors.Is(err, io.EOF) {
+			d.r = bytes.NewReader(nil)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d.closers = append(d.closers, rc)
+		r = rc
+	}
+	d.r = r
+	return nil
+}
+
+// Close closes the decoders and the body
+func (d *decodingReader) Close() error {
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		_ = d.closers[i].Close()
+	}
+	return d.body.Close()
+}
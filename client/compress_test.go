@@ -0,0 +1,249 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// gzipped returns the gzip encoding of the data
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestBaseClient_WithResponseDecompression(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	const body = `{"name":"compressed"}`
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get(acceptEncodingHeaderKey), "gzip, deflate")
+		w.Header().Set(contentEncodingHeaderKey, "gzip")
+		_, _ = w.Write(gzipped([]byte(body)))
+	})
+	mux.HandleFunc("/zlib", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentEncodingHeaderKey, "deflate")
+		zw := zlib.NewWriter(w)
+		_, _ = zw.Write([]byte(body))
+		_ = zw.Close()
+	})
+	mux.HandleFunc("/flate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentEncodingHeaderKey, "deflate")
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		_, _ = fw.Write([]byte(body))
+		_ = fw.Close()
+	})
+	mux.HandleFunc("/unknown", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentEncodingHeaderKey, "compress")
+		_, _ = w.Write([]byte(body))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentEncodingHeaderKey, "gzip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	c := NewClient(logrus.New()).WithResponseDecompression(0)
+
+	for _, path := range []string{"/gzip", "/zlib", "/flate"} {
+		resp, err := c.Get(context.Background(), u+path)
+		assert.Nil(t, err)
+		assert.Empty(t, resp.GetHeaders().Get(contentEncodingHeaderKey))
+
+		var v struct{ Name string }
+		assert.Nil(t, resp.UnmarshalJSONResponse(&v), path)
+		assert.Equal(t, "compressed", v.Name, path)
+	}
+
+	// an unknown coding is left as is
+	resp, err := c.Get(context.Background(), u+"/unknown")
+	assert.Nil(t, err)
+	assert.Equal(t, "compress", resp.GetHeaders().Get(contentEncodingHeaderKey))
+
+	// no body to decode
+	resp, err = c.Get(context.Background(), u+"/empty")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+
+	resp, err = c.Head(context.Background(), u+"/empty")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+
+	// the negotiation of the caller wins
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u+"/unknown", nil)
+	assert.Nil(t, err)
+	req.Header.Set(acceptEncodingHeaderKey, "compress")
+	_, err = c.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "compress", req.Header.Get(acceptEncodingHeaderKey))
+}
+
+func TestBaseClient_WithResponseDecompression_limit(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	bomb := gzipped(bytes.Repeat([]byte{0}, 1<<20))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(contentEncodingHeaderKey, "gzip")
+		_, _ = w.Write(bomb)
+	})
+
+	c := NewClient(logrus.New()).WithRetryMax(2).WithResponseDecompression(1 << 10)
+
+	_, err := c.Get(context.Background(), u)
+	assert.True(t, errors.Is(err, ErrDecodedBodyTooLarge))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestBaseClient_WithRequestCompression(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	large := strings.Repeat("compressible ", 100)
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get(contentEncodingHeaderKey) == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			assert.Nil(t, err)
+			body = zr
+		}
+		b, err := ioutil.ReadAll(body)
+		assert.Nil(t, err)
+
+		// the first attempt fails, the retry sends the same body
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Encoding", r.Header.Get(contentEncodingHeaderKey))
+		_, _ = w.Write(b)
+	})
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(int) time.Duration { return 0 }).
+		WithRequestCompression(100)
+
+	resp, err := c.Post(context.Background(), u, jsonContentType, large)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, "gzip", resp.GetHeaders().Get("X-Encoding"))
+
+	var body string
+	assert.Nil(t, resp.UnmarshalJSONResponse(&body))
+	assert.Equal(t, large, body)
+
+	// a small body is sent as is
+	resp, err = c.Post(context.Background(), u, jsonContentType, "small")
+	assert.Nil(t, err)
+	assert.Empty(t, resp.GetHeaders().Get("X-Encoding"))
+}
+
+func TestBaseClient_WithRequestCompression_redaction(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get(contentEncodingHeaderKey))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := &memoryWriter{}
+	sink := NewAuditSink(w, NewRedactor())
+	c := NewClient(logrus.New()).
+		WithRetryMax(0).
+		WithRequestCompression(1).
+		WithAuditSink(sink)
+
+	body := map[string]string{"username": "john", "password": "hunter2"}
+	resp, err := c.Post(context.Background(), u+"/login", jsonContentType, body)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Close())
+
+	// the dumps hold the body before its compression, so it's redacted
+	records := w.records(t)
+	assert.Len(t, records, 1)
+	assert.Empty(t, records[0].RequestBodyEncoding)
+	assert.Contains(t, records[0].RequestBody, `"username":"john"`)
+	assert.NotContains(t, records[0].RequestBody, "hunter2")
+	assert.Empty(t, records[0].RequestHeaders.Get(contentEncodingHeaderKey))
+
+	har, err := resp.HAR(NewRedactor())
+	assert.Nil(t, err)
+	assert.NotContains(t, har.Log.Entries[0].Request.PostData.Text, "hunter2")
+
+	cmd, err := resp.ToCurl(NewRedactor())
+	assert.Nil(t, err)
+	assert.NotContains(t, cmd, "hunter2")
+	assert.NotContains(t, cmd, "gzip")
+}
+
+func TestRegisterContentDecoder(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	// a reversing coding, registered once for the whole package
+	RegisterContentDecoder("X-Test-Reverse", func(r io.Reader) (io.ReadCloser, error) {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	assert.Contains(t, acceptEncoding(), "x-test-reverse")
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// the reversing coding applied after gzip
+		w.Header().Set(contentEncodingHeaderKey, "gzip, x-test-reverse")
+		b := gzipped([]byte("stacked"))
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		_, _ = w.Write(b)
+	})
+
+	resp, err := NewClient(logrus.New()).WithResponseDecompression(0).Get(context.Background(), u)
+	assert.Nil(t, err)
+	body, err := resp.GetStringBody()
+	assert.Nil(t, err)
+	assert.Equal(t, "stacked", body)
+}
@@ -5,7 +5,7 @@ import "time"
 // Misc.
 const (
 	defaultRetryMax   int   = 2
-	responseReadLimit int64 = 4096
+	defaultDrainLimit int64 = 4096
 )
 
 // Auth schemes
@@ -16,11 +16,39 @@ const (
 
 // Header keys/values used for requests
 const (
-	authorizationHeaderKey string = "Authorization"
-	contentTypeHeaderKey   string = "Content-Type"
-	retryAfterHeaderKey    string = "Retry-After"
-	userAgentHeaderKey     string = "User-Agent"
-	userAgentHeaderValue   string = "go-http-client"
+	acceptHeaderKey          string = "Accept"
+	acceptEncodingHeaderKey  string = "Accept-Encoding"
+	ageHeaderKey             string = "Age"
+	attemptHeaderKey         string = "X-Attempt"
+	authorizationHeaderKey   string = "Authorization"
+	cacheControlHeaderKey    string = "Cache-Control"
+	contentEncodingHeaderKey string = "Content-Encoding"
+	contentLengthHeaderKey   string = "Content-Length"
+	contentTypeHeaderKey     string = "Content-Type"
+	csrfTokenHeaderKey       string = "X-CSRF-Token"
+	dateHeaderKey            string = "Date"
+	deprecationHeaderKey     string = "Deprecation"
+	etagHeaderKey            string = "ETag"
+	expiresHeaderKey         string = "Expires"
+	ifModifiedSinceHeaderKey string = "If-Modified-Since"
+	ifNoneMatchHeaderKey     string = "If-None-Match"
+	lastModifiedHeaderKey    string = "Last-Modified"
+	linkHeaderKey            string = "Link"
+	pragmaHeaderKey          string = "Pragma"
+	requestIDHeaderKey       string = "X-Request-ID"
+	retryAfterHeaderKey      string = "Retry-After"
+	sunsetHeaderKey          string = "Sunset"
+	userAgentHeaderKey       string = "User-Agent"
+	userAgentHeaderValue     string = "go-http-client"
+	varyHeaderKey            string = "Vary"
+)
+
+// Content types
+const (
+	formContentType string = "application/x-www-form-urlencoded"
+	jsonContentType string = "application/json"
+
+	prometheusContentType string = "text/plain; version=0.0.4; charset=utf-8"
 )
 
 // http.Transport constants
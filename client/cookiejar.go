@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storedCookie is the on-disk representation of a cookie
+type storedCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Path     string        `json:"path,omitempty"`
+	Domain   string        `json:"domain,omitempty"`
+	Expires  *time.Time    `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// key returns the identity of the cookie as defined by RFC 6265
+func (c storedCookie) key() string {
+	u, _ := url.Parse(c.URL)
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if domain == "" && u != nil {
+		domain = strings.ToLower(u.Hostname())
+	}
+	return domain + ";" + c.Path + ";" + c.Name
+}
+
+// PersistentCookieJar is a net/http/cookiejar based http.CookieJar which can be
+// saved to and loaded from disk, so a session survives between runs
+type PersistentCookieJar struct {
+	*cookiejar.Jar
+
+	path string
+
+	mu      sync.Mutex
+	cookies map[string]storedCookie
+	now     func() time.Time
+}
+
+// NewPersistentCookieJar creates a new PersistentCookieJar which is saved to the
+// provided path. The cookies already stored in the file are loaded
+func NewPersistentCookieJar(path string) (*PersistentCookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &PersistentCookieJar{
+		Jar:     jar,
+		path:    path,
+		cookies: make(map[string]storedCookie),
+		now:     time.Now,
+	}
+
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// SetCookies stores the cookies in the jar and records them for persistence
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	for _, c := range cookies {
+		sc := storedCookie{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+		}
+
+		expires := c.Expires
+		switch {
+		case c.MaxAge < 0:
+			expires = now
+		case c.MaxAge > 0:
+			expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		if !expires.IsZero() {
+			if !expires.After(now) {
+				delete(j.cookies, sc.key())
+				continue
+			}
+			sc.Expires = &expires
+		}
+
+		j.cookies[sc.key()] = sc
+	}
+}
+
+// Save writes the cookies which are not expired to the file
+func (j *PersistentCookieJar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	cookies := make([]storedCookie, 0, len(j.cookies))
+	for k, c := range j.cookies {
+		if c.Expires != nil && !c.Expires.After(now) {
+			delete(j.cookies, k)
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+
+	b, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(j.path, b, 0600)
+}
+
+// load reads the cookies from the file into the jar
+func (j *PersistentCookieJar) load() error {
+	b, err := ioutil.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var cookies []storedCookie
+	if err := json.Unmarshal(b, &cookies); err != nil {
+		return err
+	}
+
+	now := j.now()
+	for _, sc := range cookies {
+		if sc.Expires != nil && !sc.Expires.After(now) {
+			continue
+		}
+
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			continue
+		}
+
+		c := &http.Cookie{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Path:     sc.Path,
+			Domain:   sc.Domain,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+			SameSite: sc.SameSite,
+		}
+		if sc.Expires != nil {
+			c.Expires = *sc.Expires
+		}
+
+		j.Jar.SetCookies(u, []*http.Cookie{c})
+		j.cookies[sc.key()] = sc
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentCookieJar(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	u, _ := url.Parse("https://app.local/login")
+	other, _ := url.Parse("https://sub.example.local/")
+
+	jar, err := NewPersistentCookieJar(path)
+	assert.Nil(t, err)
+
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "s1", Path: "/"},
+		{Name: "remember", Value: "r1", Path: "/", MaxAge: 3600},
+		{Name: "gone", Value: "g1", Path: "/", Expires: time.Now().Add(-time.Hour)},
+	})
+	jar.SetCookies(other, []*http.Cookie{
+		{Name: "domain", Value: "d1", Path: "/", Domain: ".example.local", Expires: time.Now().Add(time.Hour)},
+	})
+	assert.Len(t, jar.Cookies(u), 2)
+
+	err = jar.Save()
+	assert.Nil(t, err)
+
+	// load the jar in a new run
+	loaded, err := NewPersistentCookieJar(path)
+	assert.Nil(t, err)
+
+	cookies := map[string]string{}
+	for _, c := range loaded.Cookies(u) {
+		cookies[c.Name] = c.Value
+	}
+	assert.Equal(t, map[string]string{"session": "s1", "remember": "r1"}, cookies)
+
+	// domain cookies are kept as domain cookies
+	another, _ := url.Parse("https://www.example.local/")
+	assert.Equal(t, []*http.Cookie{{Name: "domain", Value: "d1"}}, loaded.Cookies(another))
+
+	// deleted cookies are not persisted
+	loaded.SetCookies(u, []*http.Cookie{{Name: "session", Value: "", Path: "/", MaxAge: -1}})
+	assert.Nil(t, loaded.Save())
+
+	reloaded, err := NewPersistentCookieJar(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []*http.Cookie{{Name: "remember", Value: "r1"}}, reloaded.Cookies(u))
+}
+
+func TestNewPersistentCookieJar_invalidFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPersistentCookieJar(t.TempDir())
+	assert.NotNil(t, err)
+}
@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
@@ -35,6 +38,82 @@ type BaseClient struct {
 	// auth
 	auth auth
 
+	// client-wide credential provider, used when no store entry matches
+	credentialProvider CredentialProvider
+
+	// per-host / per-URL prefix credentials
+	credentialStore *CredentialStore
+
+	// cookie session with CSRF handling
+	session *Session
+
+	// single-flight credentials refresh
+	authRefresher        *authRefresher
+	authRefreshPredicate AuthRefreshPredicate
+
+	// metrics collector
+	metrics MetricsCollector
+
+	// tracer
+	tracer Tracer
+
+	// HAR session recorder
+	harRecorder *HARRecorder
+
+	// audit log
+	auditSink *AuditSink
+
+	// buffer the response body of every attempt, not only of the final one
+	attemptBodyDumps bool
+
+	// peer certificate expiry warning
+	certificateWatcher *certificateWatcher
+
+	// deprecated endpoints report
+	deprecationTracker *DeprecationTracker
+
+	// HTTP cache, always revalidating the stored responses in the conditional requests mode
+	cacheStore      CacheStore
+	cacheRevalidate bool
+
+	// background revalidations in progress, by cache key
+	cacheMu           sync.Mutex
+	cacheRevalidating map[string]bool
+
+	// fallbacks of the failed calls
+	fallback      FallbackFunc
+	fallbackStore *FallbackStore
+
+	// single-flight of the identical GETs
+	coalescer *coalescer
+
+	// content codings of the responses, and of the request bodies above the threshold
+	decompression        bool
+	maxDecodedSize       int64
+	compressionThreshold int64
+
+	// stream the response bodies of every call
+	streaming         bool
+	streamIdleTimeout time.Duration
+
+	// maximum size of the response bodies, and the bytes read from the discarded ones
+	maxResponseSize int64
+	drainLimit      int64
+
+	// host overrides and resolver of the connections
+	dialer *hostDialer
+
+	// base URLs of the requests with a relative URL
+	endpointPool *EndpointPool
+
+	// log the attempts as curl commands
+	curlLogging  bool
+	curlRedactor *Redactor
+
+	// request ID header, disabled when empty
+	requestIDHeader    string
+	requestIDGenerator RequestIDGenerator
+
 	// logger
 	logger *logrus.Logger
 
@@ -44,11 +123,14 @@ type BaseClient struct {
 // NewClient creates a new BaseClient with default values
 func NewClient(l *logrus.Logger) *BaseClient {
 	return &BaseClient{
-		hc:              getHTTPClient(),
-		retryMax:        defaultRetryMax,
-		retryPolicy:     DefaultRetryPolicy,
-		backoffStrategy: DefaultBackoffStrategy,
-		logger:          l,
+		hc:                 getHTTPClient(),
+		retryMax:           defaultRetryMax,
+		retryPolicy:        DefaultRetryPolicy,
+		backoffStrategy:    DefaultBackoffStrategy,
+		drainLimit:         defaultDrainLimit,
+		requestIDHeader:    requestIDHeaderKey,
+		requestIDGenerator: NewUUIDv4,
+		logger:             l,
 	}
 }
 
@@ -90,16 +172,152 @@ func (c *BaseClient) WithCustomAuth(scheme, token string) *BaseClient {
 	return c
 }
 
+// WithCredentialProvider sets the client-wide credential provider and returns the BaseClient.
+// The provider is queried on every attempt, so rotated credentials are picked up
+func (c *BaseClient) WithCredentialProvider(p CredentialProvider) *BaseClient {
+	c.credentialProvider = p
+	return c
+}
+
+// WithCredentialStore sets the per-host credential store and returns the BaseClient.
+// A matching store entry wins over the client-wide provider and the static auth
+func (c *BaseClient) WithCredentialStore(s *CredentialStore) *BaseClient {
+	c.credentialStore = s
+	return c
+}
+
+// callState holds the data collected while performing a logical call
+type callState struct {
+	attempts int
+	gaveUp   bool
+
+	// context of the span of the logical call
+	traceCtx context.Context
+}
+
 // Do wraps calling an HTTP method with retries
 func (c *BaseClient) Do(req *Request) (*Response, error) {
+	if c.coalescer != nil && c.coalescable(req) {
+		return c.coalesce(req)
+	}
+	return c.call(req)
+}
+
+// call performs the logical call and runs its hooks
+func (c *BaseClient) call(req *Request) (*Response, error) {
+	var state callState
+
+	// pick the endpoint first, the hooks and the cache see the absolute URL
+	if err := c.selectEndpoint(req, nil); err != nil {
+		return nil, err
+	}
+
+	// negotiated before the cache lookup, the stored variants depend on it
+	c.negotiateEncoding(req)
+
+	method, host := req.Method, req.URL.Host
+	start := time.Now()
+
+	if c.metrics != nil {
+		c.metrics.RequestStarted(method, host)
+	}
+
+	var span Span
+	state.traceCtx = req.Context()
+	if c.tracer != nil {
+		state.traceCtx, span = c.tracer.Start(req.Context(), "HTTP "+method)
+		span.SetAttribute(AttributeHTTPMethod, method)
+		span.SetAttribute(AttributeHTTPURL, req.URL.String())
+	}
+
+	resp, err := c.doCached(req, &state)
+
+	if span != nil {
+		recordSpanResult(span, resp, err)
+		// no attempt for the responses served from the cache
+		if state.attempts > 0 {
+			span.SetAttribute(AttributeHTTPRetryCount, state.attempts-1)
+		}
+		span.End()
+	}
+
+	if c.harRecorder != nil && resp != nil {
+		if recordErr := c.harRecorder.Record(resp); recordErr != nil {
+			c.getLogger().WithError(recordErr).Errorf("%s %s HAR recording failed", method, req.URL)
+		}
+	}
+
+	if c.deprecationTracker != nil && resp != nil && resp.Deprecation != nil {
+		c.deprecationTracker.observe(method, req.URL, resp.Deprecation)
+	}
+
+	if c.auditSink != nil {
+		u := *req.URL
+		c.auditSink.record(auditEvent{
+			start:    start,
+			method:   method,
+			url:      &u,
+			attempts: state.attempts,
+			duration: time.Since(start),
+			resp:     resp,
+			err:      err,
+		})
+	}
+
+	if c.metrics != nil {
+		m := RequestMetrics{
+			Method:      method,
+			Host:        host,
+			StatusClass: statusClass(nil),
+			Outcome:     OutcomeSuccess,
+			Attempts:    state.attempts,
+			Duration:    time.Since(start),
+			GaveUp:      state.gaveUp,
+		}
+		if resp != nil {
+			m.StatusClass = statusClass(resp.RawResponse)
+		}
+		if err != nil {
+			m.Outcome = OutcomeError
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				m.Outcome = OutcomeCanceled
+			}
+		}
+		c.metrics.RequestFinished(m)
+	}
+
+	// the hooks above observe the failure, the caller gets the fallback
+	if err != nil || state.gaveUp {
+		return c.applyFallback(req, resp, err, callError(resp, err, state.gaveUp))
+	}
+
+	return resp, err
+}
+
+// do performs the request with retries
+func (c *BaseClient) do(req *Request, state *callState) (*Response, error) {
 	// get the logger
-	logger := c.getLogger()
+	logger := logrus.NewEntry(c.getLogger())
+
+	// get the request ID, kept across the attempts
+	requestID, err := c.requestID(req)
+	if err != nil {
+		logger.WithError(err).Errorf("%s %s request ID generation failed", req.Method, req.URL)
+		return nil, err
+	}
+	if requestID != "" {
+		logger = logger.WithField("request_id", requestID)
+	}
 
 	// log the action
 	logger.Debugf("%s %s", req.Method, req.URL)
 
 	// setup auth
-	_ = req.setupAuth(c.auth.Scheme, c.auth.Token)
+	authGen, err := c.prepareRequest(req)
+	if err != nil {
+		logger.WithError(err).Errorf("%s %s credential lookup failed", req.Method, req.URL)
+		return nil, err
+	}
 
 	// re-create the http client
 	c.clientInit.Do(func() {
@@ -112,26 +330,186 @@ func (c *BaseClient) Do(req *Request) (*Response, error) {
 	var resp *http.Response
 	var dataDump DataDump
 	var attempt int
-	var shouldRetry bool
+	var shouldRetry, authReplayed bool
 	var doErr, retryErr error
+	var timings []AttemptTiming
+	var attemptDumps []*DataDump
+	var streamResponse func(resp *http.Response) *BodyStream
+
+	// the timing hooks are attached to the original context on every attempt
+	ctx := req.Context()
+
+	// a streamed body outlives the call, its stream cancels the context on
+	// close or when idle
+	streaming, streamIdleTimeout := c.streams(req)
+	if streaming {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		var streamed bool
+		defer func() {
+			if !streamed {
+				cancel()
+			}
+		}()
+		streamResponse = func(resp *http.Response) *BodyStream {
+			streamed = true
+			stream := newBodyStream(resp.Body, resp.ContentLength, streamIdleTimeout, cancel)
+			resp.Body = stream
+			return stream
+		}
+	}
+
+	// compress the body once, the attempts rewind the compressed body
+	if err := c.compressRequest(req); err != nil {
+		logger.WithError(err).Errorf("%s %s request compression failed", req.Method, req.URL)
+		return nil, err
+	}
 
-	// set the request dump
-	dataDump.RequestDump, _ = httputil.DumpRequestOut(req.Request, req.body != nil)
+	// the response bodies of every attempt are only buffered for their consumers
+	attemptBodies := c.dumpsAttemptBodies()
+
+	// set the request dump, with the body before its compression
+	dataDump.RequestDump, _ = httputil.DumpRequestOut(req.uncompressed().Request, req.body != nil)
 
 	for i := 0; ; i++ {
 		attempt++
+		state.attempts = attempt
 
 		var code int // HTTP response code
 
 		// always rewind the request body when non-nil
 		if req.body != nil {
+			if s, ok := req.body.(io.Seeker); ok {
+				_, _ = s.Seek(0, io.SeekStart)
+			}
 			req.Body = ioutil.NopCloser(req.body)
 		}
 
+		c.setAttemptHeaders(req, requestID, attempt)
+
+		// start the span of the attempt and propagate the trace context
+		var attemptSpan Span
+		if c.tracer != nil {
+			_, attemptSpan = c.tracer.Start(state.traceCtx, fmt.Sprintf("HTTP %s attempt", req.Method))
+			attemptSpan.SetAttribute(AttributeHTTPAttempt, attempt)
+			injectTraceContext(req, attemptSpan.SpanContext())
+		} else if sc, ok := SpanContextFromContext(req.Context()); ok {
+			injectTraceContext(req, sc)
+		}
+
+		if c.curlLogging {
+			if cmd, curlErr := req.ToCurl(c.curlRedactor); curlErr == nil {
+				logger.Debugf("%s %s attempt %d: %s", req.Method, req.URL, attempt, cmd)
+			}
+			// a body which can't be rewound is buffered by ToCurl
+			if req.body != nil {
+				req.Body = ioutil.NopCloser(req.body)
+			}
+		}
+
 		// attempt the request
+		attemptStart := time.Now()
+		timer := newAttemptTimer()
+
+		// dump the request without the timing hooks, the dump runs a fake round trip
+		attemptDump := &DataDump{}
+		req.Request = req.Request.WithContext(ctx)
+		attemptDump.RequestDump, _ = httputil.DumpRequestOut(req.uncompressed().Request, req.body != nil)
+		attemptDumps = append(attemptDumps, attemptDump)
+
+		req.Request = req.Request.WithContext(timer.withContext(ctx))
+
+		endpoint := req.endpoint
+		if endpoint != nil {
+			c.endpointPool.acquire(endpoint)
+		}
+
 		resp, doErr = c.hc.Do(req.Request)
 		if resp != nil {
 			code = resp.StatusCode
+
+			// reject the announced oversized body, limit the decoded one while reading
+			maxSize := c.maxResponseSizeOf(req)
+			if maxSize > 0 && resp.ContentLength > maxSize && !bodyless(resp) {
+				doErr = fmt.Errorf("%w: %d bytes announced, the maximum is %d", ErrBodyTooLarge, resp.ContentLength, maxSize)
+			}
+			c.decodeResponse(resp)
+			if maxSize > 0 {
+				resp.Body = &limitedBody{body: resp.Body, limit: maxSize}
+			}
+
+			// keep the response of every attempt, the body is buffered when a
+			// consumer needs the one of every attempt, else only the final one is
+			if streaming || doErr != nil || !attemptBodies {
+				attemptDump.ResponseDump, _ = dumpResponseHead(resp)
+			} else {
+				doErr = dumpResponseBody(attemptDump, resp)
+			}
+		}
+
+		// a call canceled by the caller doesn't count against the endpoint
+		if endpoint != nil {
+			c.endpointPool.release(endpoint, (resp != nil && code < http.StatusInternalServerError) || req.Context().Err() != nil)
+		}
+
+		timing := timer.finish(attempt, code)
+		timings = append(timings, timing)
+		logger.WithFields(timing.fields()).Debugf("%s %s attempt %d timing", req.Method, req.URL, attempt)
+
+		if attemptSpan != nil {
+			recordSpanResult(attemptSpan, nil, doErr)
+			if resp != nil {
+				attemptSpan.SetAttribute(AttributeHTTPStatusCode, code)
+			}
+			attemptSpan.End()
+		}
+
+		if c.metrics != nil {
+			outcome := OutcomeSuccess
+			if doErr != nil {
+				outcome = OutcomeError
+			}
+			c.metrics.AttemptFinished(AttemptMetrics{
+				Method:      req.Method,
+				Host:        req.URL.Host,
+				StatusClass: statusClass(resp),
+				Outcome:     outcome,
+				Attempt:     attempt,
+				Duration:    time.Since(attemptStart),
+			})
+		}
+
+		// capture the CSRF token of the session
+		if c.session != nil {
+			c.session.capture(resp)
+		}
+
+		// refresh the credentials and replay the request once, a replay
+		// doesn't count as a retry
+		if doErr == nil && !authReplayed && c.shouldRefreshAuth(req, resp) {
+			authReplayed = true
+
+			replay, refreshErr := c.authRefresher.refreshFrom(req.Context(), authGen)
+			if refreshErr != nil {
+				logger.WithError(refreshErr).Errorf("%s %s auth refresh failed", req.Method, req.URL)
+			}
+
+			if replay {
+				drainBodyErr := drainBody(resp.Body, c.drainLimit)
+				if drainBodyErr != nil {
+					logger.WithError(drainBodyErr).Error("error reading response body")
+				}
+
+				logger.Debugf("%s %s (status: %d): replaying with refreshed credentials", req.Method, req.URL, code)
+
+				if authGen, err = c.renewRequest(req); err != nil {
+					logger.WithError(err).Errorf("%s %s credential lookup failed", req.Method, req.URL)
+					return nil, err
+				}
+
+				i--
+				continue
+			}
 		}
 
 		// check the retry
@@ -148,12 +526,13 @@ func (c *BaseClient) Do(req *Request) (*Response, error) {
 		// check the remaining number of retries
 		remain := c.retryMax - i
 		if remain == 0 {
+			state.gaveUp = true
 			break
 		}
 
-		// consume any response to reuse the connection
-		if doErr == nil {
-			drainBodyErr := drainBody(resp.Body)
+		// consume any response to reuse the connection, also the rejected one
+		if resp != nil {
+			drainBodyErr := drainBody(resp.Body, c.drainLimit)
 			if drainBodyErr != nil {
 				logger.WithError(drainBodyErr).Error("error reading response body")
 			}
@@ -174,6 +553,12 @@ func (c *BaseClient) Do(req *Request) (*Response, error) {
 		}
 		logger.Debugf("%s: retrying in %s (%d left)", desc, wait, remain)
 
+		timings[len(timings)-1].Backoff = wait
+
+		if c.metrics != nil {
+			c.metrics.BackoffStarted(req.Method, req.URL.Host, wait)
+		}
+
 		select {
 		case <-req.Context().Done():
 			c.hc.CloseIdleConnections()
@@ -181,38 +566,77 @@ func (c *BaseClient) Do(req *Request) (*Response, error) {
 		case <-time.After(wait):
 		}
 
-		// make shallow copy of http.Request so that we can modify its body
-		// without racing against the closeBody call in persistConn.writeLoop
-		httpReq := *req.Request
-		req.Request = &httpReq
+		// prefer another endpoint for the retry
+		if req.endpoint != nil {
+			if err = c.selectEndpoint(req, req.endpoint); err != nil {
+				logger.WithError(err).Errorf("%s %s endpoint selection failed", req.Method, req.URL)
+				return nil, err
+			}
+		}
+
+		// refresh the auth, the credential may have been rotated meanwhile
+		if authGen, err = c.renewRequest(req); err != nil {
+			logger.WithError(err).Errorf("%s %s credential lookup failed", req.Method, req.URL)
+			return nil, err
+		}
+	}
+
+	// buffer the body of the final response, counted in the duration of its attempt
+	if resp != nil && doErr == nil && !streaming && !attemptBodies {
+		doErr = dumpResponseBody(attemptDumps[len(attemptDumps)-1], resp)
+		last := &timings[len(timings)-1]
+		last.Total = time.Since(last.Start)
 	}
 
-	// set the raw response
+	// set the raw response and the timings
 	respObj.RawResponse = resp
+	respObj.Timings = timings
+	respObj.AttemptDumps = attemptDumps
+	respObj.requestURL = req.URL
+	respObj.RequestID = requestID
+
+	// set the retirement announcement
+	if resp != nil {
+		respObj.NotModified = resp.StatusCode == http.StatusNotModified
+		respObj.Deprecation = parseDeprecation(resp.Header, req.URL)
+	}
+
+	// set the TLS details and check the peer certificates
+	if resp != nil && resp.TLS != nil {
+		respObj.TLS = newTLSInfo(resp.TLS)
+		if c.certificateWatcher != nil {
+			c.certificateWatcher.check(req.URL.Hostname(), respObj.TLS)
+		}
+	}
 
 	// return successful response
 	if doErr == nil && retryErr == nil && !shouldRetry {
 
-		// set the response dump
-		dataDump.ResponseDump, _ = httputil.DumpResponse(resp, true)
+		// set the response dump, already taken for the last attempt
+		dataDump.ResponseDump = attemptDumps[len(attemptDumps)-1].ResponseDump
 
 		// set data dump
 		respObj.DataDump = &dataDump
 
+		// hand the body over to the caller
+		if streaming {
+			respObj.Stream = streamResponse(resp)
+		}
+
 		// return the response
 		return &respObj, nil
 	}
 
 	defer c.hc.CloseIdleConnections()
 
-	err := doErr
+	err = doErr
 	if retryErr != nil {
 		err = retryErr
 	}
 
 	// consume the response
 	if resp != nil {
-		drainBodyErr := drainBody(resp.Body)
+		drainBodyErr := drainBody(resp.Body, c.drainLimit)
 		if drainBodyErr != nil {
 			logger.WithError(drainBodyErr).Error("error reading response body")
 		}
@@ -224,6 +648,82 @@ func (c *BaseClient) Do(req *Request) (*Response, error) {
 	return &respObj, err
 }
 
+// prepareRequest sets up the auth and the session data of the request
+// before each attempt. It returns the generation of the applied credentials
+func (c *BaseClient) prepareRequest(req *Request) (uint64, error) {
+	authGen := c.authGeneration()
+	if err := c.authenticate(req); err != nil {
+		return authGen, err
+	}
+	if c.session != nil {
+		c.session.inject(req)
+	}
+	return authGen, nil
+}
+
+// renewRequest prepares the request for the next attempt
+func (c *BaseClient) renewRequest(req *Request) (uint64, error) {
+	// make shallow copy of http.Request so that we can modify its body
+	// without racing against the closeBody call in persistConn.writeLoop
+	httpReq := *req.Request
+	httpReq.Header = req.Header.Clone()
+	req.Request = &httpReq
+
+	return c.prepareRequest(req)
+}
+
+// authenticate resolves the credential for the request URL and sets it up.
+// The credential store is checked first, then the client-wide provider
+// and finally the static auth
+func (c *BaseClient) authenticate(req *Request) error {
+	if req.skipAuth {
+		return nil
+	}
+
+	// the credential of the previous attempt may belong to another host
+	if req.authApplied {
+		req.Header.Del(authorizationHeaderKey)
+		req.authApplied = false
+	}
+
+	var provider CredentialProvider
+	if c.credentialStore != nil {
+		provider = c.credentialStore.Provider(req.URL)
+	}
+	if provider == nil {
+		provider = c.credentialProvider
+	}
+	if provider == nil {
+		return req.setupAuth(c.auth.Scheme, c.auth.Token)
+	}
+
+	cred, err := provider.Credential(req.Context(), req.URL)
+	if err != nil {
+		return err
+	}
+	if cred == nil {
+		return nil
+	}
+	return req.setupAuth(cred.Scheme, cred.Token)
+}
+
+// callerCredentials checks if the credential of the request may differ
+// between callers, without resolving it: it is set by the caller or comes from
+// a provider, which may read the context. The static auth is the same for all
+func (c *BaseClient) callerCredentials(req *Request) bool {
+	if req.skipAuth {
+		return false
+	}
+	if req.Header.Get(authorizationHeaderKey) != "" && !req.authApplied {
+		return true
+	}
+	// the store entry of a relative URL depends on the endpoint picked later
+	if c.credentialStore != nil && (!req.URL.IsAbs() || c.credentialStore.Provider(req.URL) != nil) {
+		return true
+	}
+	return c.credentialProvider != nil
+}
+
 // getHTTPClient returns a new http.Client with similar default
 // values to http.Client but with a custom http.Transport
 func getHTTPClient() *http.Client {
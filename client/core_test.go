@@ -25,6 +25,7 @@ func TestNewClient(t *testing.T) {
 	assert.IsType(t, new(RetryPolicy), &result.retryPolicy)
 	assert.IsType(t, new(BackoffStrategy), &result.backoffStrategy)
 	assert.Equal(t, auth{}, result.auth)
+	assert.Equal(t, requestIDHeaderKey, result.requestIDHeader)
 	assert.IsType(t, &logrus.Logger{}, result.logger)
 }
 
@@ -0,0 +1,460 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential holds the scheme and the token that are set in the
+// Authorization header of a request
+type Credential struct {
+	Scheme string
+	Token  string
+}
+
+// CredentialProvider provides the credential used for a request URL.
+// A nil credential and a nil error means that no auth is applied
+type CredentialProvider interface {
+	Credential(ctx context.Context, u *url.URL) (*Credential, error)
+}
+
+// CredentialProviderFunc is an adapter to allow the use of ordinary
+// functions as credential providers
+type CredentialProviderFunc func(ctx context.Context, u *url.URL) (*Credential, error)
+
+// Credential calls f(ctx, u)
+func (f CredentialProviderFunc) Credential(ctx context.Context, u *url.URL) (*Credential, error) {
+	return f(ctx, u)
+}
+
+// StaticCredentials always returns the same credential
+type StaticCredentials struct {
+	credential Credential
+}
+
+// NewStaticCredentials creates a new StaticCredentials provider
+func NewStaticCredentials(scheme, token string) *StaticCredentials {
+	return &StaticCredentials{Credential{scheme, token}}
+}
+
+// NewStaticBasicCredentials creates a new StaticCredentials provider for basic auth
+func NewStaticBasicCredentials(username, password string) *StaticCredentials {
+	return NewStaticCredentials(basicAuthScheme, basicAuth(username, password))
+}
+
+// Credential returns the static credential
+func (p *StaticCredentials) Credential(_ context.Context, _ *url.URL) (*Credential, error) {
+	c := p.credential
+	return &c, nil
+}
+
+// EnvCredentials reads the token from an environment variable on every
+// request, so a rotated value is picked up without rebuilding the client
+type EnvCredentials struct {
+	scheme   string
+	tokenVar string
+}
+
+// NewEnvCredentials creates a new EnvCredentials provider
+func NewEnvCredentials(scheme, tokenVar string) *EnvCredentials {
+	return &EnvCredentials{scheme, tokenVar}
+}
+
+// Credential returns the credential built from the environment variable
+func (p *EnvCredentials) Credential(_ context.Context, _ *url.URL) (*Credential, error) {
+	token, ok := os.LookupEnv(p.tokenVar)
+	if !ok || token == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", p.tokenVar)
+	}
+	return &Credential{p.scheme, token}, nil
+}
+
+// FileCredentials reads the token from a file and re-reads it when the
+// file changes (e.g. a mounted Kubernetes secret)
+type FileCredentials struct {
+	scheme string
+	path   string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	token   string
+}
+
+// NewFileCredentials creates a new FileCredentials provider
+func NewFileCredentials(scheme, path string) *FileCredentials {
+	return &FileCredentials{scheme: scheme, path: path}
+}
+
+// Credential returns the credential built from the file content
+func (p *FileCredentials) Credential(_ context.Context, _ *url.URL) (*Credential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// os.Stat follows symlinks, so the atomic swap of a mounted secret is detected too
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.token == "" || !fi.ModTime().Equal(p.modTime) || fi.Size() != p.size {
+		b, err := ioutil.ReadFile(p.path)
+		if err != nil {
+			return nil, err
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return nil, fmt.Errorf("credentials file %s is empty", p.path)
+		}
+		p.token, p.modTime, p.size = token, fi.ModTime(), fi.Size()
+	}
+
+	return &Credential{p.scheme, p.token}, nil
+}
+
+// netrcMachine holds a single machine entry of a .netrc file
+type netrcMachine struct {
+	login    string
+	password string
+}
+
+// NetrcCredentials looks up basic auth credentials by host in a .netrc file.
+// The file is re-read when it changes
+type NetrcCredentials struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	machines map[string]netrcMachine
+	def      *netrcMachine
+}
+
+// NewNetrcCredentials creates a new NetrcCredentials provider. An empty path
+// uses $NETRC or ~/.netrc
+func NewNetrcCredentials(path string) *NetrcCredentials {
+	if path == "" {
+		path = defaultNetrcPath()
+	}
+	return &NetrcCredentials{path: path}
+}
+
+// Credential returns the basic auth credential of the machine matching the URL host
+func (p *NetrcCredentials) Credential(_ context.Context, u *url.URL) (*Credential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.machines == nil || !fi.ModTime().Equal(p.modTime) {
+		b, err := ioutil.ReadFile(p.path)
+		if err != nil {
+			return nil, err
+		}
+		p.machines, p.def = parseNetrc(string(b))
+		p.modTime = fi.ModTime()
+	}
+
+	m, ok := p.machines[strings.ToLower(u.Hostname())]
+	if !ok {
+		if p.def == nil {
+			return nil, nil
+		}
+		m = *p.def
+	}
+
+	return &Credential{basicAuthScheme, basicAuth(m.login, m.password)}, nil
+}
+
+// defaultNetrcPath returns the path of the .netrc file used by curl & co.
+func defaultNetrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".netrc"
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc parses the content of a .netrc file and returns the machine
+// entries keyed by host and the default entry (if any)
+func parseNetrc(data string) (map[string]netrcMachine, *netrcMachine) {
+	machines := make(map[string]netrcMachine)
+	var def *netrcMachine
+
+	var current *netrcMachine
+	var host string
+	var inMacro bool
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if host == "" {
+			def = current
+		} else if _, ok := machines[host]; !ok {
+			machines[host] = *current
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// a macro definition ends at the first empty line
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				flush()
+				if i+1 < len(fields) {
+					i++
+					host = strings.ToLower(fields[i])
+					current = &netrcMachine{}
+				}
+			case "default":
+				flush()
+				host = ""
+				current = &netrcMachine{}
+			case "login":
+				if current != nil && i+1 < len(fields) {
+					i++
+					current.login = fields[i]
+				}
+			case "password":
+				if current != nil && i+1 < len(fields) {
+					i++
+					current.password = fields[i]
+				}
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	flush()
+
+	return machines, def
+}
+
+// urlPattern is a host (optionally with port or a leading "*." wildcard) or a URL prefix
+type urlPattern struct {
+	scheme string
+	host   string
+	path   string
+
+	// port of a host pattern, any port when empty
+	port string
+
+	prefix   bool
+	wildcard bool
+}
+
+// credentialEntry holds a single entry of the CredentialStore
+type credentialEntry struct {
+	urlPattern
+
+	provider CredentialProvider
+}
+
+// CredentialStore keeps credential providers keyed by host or URL prefix,
+// so one client can talk to several APIs without leaking a token to other hosts
+type CredentialStore struct {
+	mu      sync.RWMutex
+	entries []credentialEntry
+}
+
+// NewCredentialStore creates a new empty CredentialStore
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{}
+}
+
+// Add registers the provider for the pattern.
+// The pattern is either a host ("api.example.com"), a host with port
+// ("api.example.com:8443"), a wildcard host ("*.example.com") or a URL
+// prefix ("https://api.example.com/v2"). When several patterns match a
+// request, URL prefixes win over hosts and longer prefixes win over shorter ones
+func (s *CredentialStore) Add(pattern string, p CredentialProvider) error {
+	up, err := parseURLPattern(pattern)
+	if err != nil {
+		return err
+	}
+	e := credentialEntry{urlPattern: up, provider: p}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+	sort.SliceStable(s.entries, func(i, j int) bool {
+		return s.entries[i].rank() > s.entries[j].rank()
+	})
+
+	return nil
+}
+
+// Provider returns the provider registered for the URL or nil when
+// there is no matching pattern
+func (s *CredentialStore) Provider(u *url.URL) CredentialProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		if e.matches(u) {
+			return e.provider
+		}
+	}
+	return nil
+}
+
+// Credential returns the credential of the provider matching the URL,
+// so the store itself can be used as a CredentialProvider
+func (s *CredentialStore) Credential(ctx context.Context, u *url.URL) (*Credential, error) {
+	p := s.Provider(u)
+	if p == nil {
+		return nil, nil
+	}
+	return p.Credential(ctx, u)
+}
+
+// parseURLPattern parses a host, a host with port, a wildcard host or a URL prefix
+func parseURLPattern(pattern string) (urlPattern, error) {
+	var e urlPattern
+
+	if strings.Contains(pattern, "://") {
+		u, err := url.Parse(pattern)
+		if err != nil {
+			return e, err
+		}
+		if u.Host == "" {
+			return e, fmt.Errorf("invalid pattern %q: missing host", pattern)
+		}
+		e.prefix = true
+		e.scheme = strings.ToLower(u.Scheme)
+		e.host = canonicalHost(u)
+		e.path = u.Path
+		return e, nil
+	}
+
+	if pattern == "" {
+		return e, fmt.Errorf("invalid pattern: empty host")
+	}
+	host, port, err := splitHostPort(strings.ToLower(pattern))
+	if err != nil {
+		return e, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	e.host = host
+	e.port = port
+	e.wildcard = strings.HasPrefix(e.host, "*.")
+	return e, nil
+}
+
+// rank returns how specific the pattern is
+func (e urlPattern) rank() int {
+	if e.prefix {
+		return 3000 + len(e.path)
+	}
+
+	rank := 2000
+	if e.wildcard {
+		rank = 1000 + len(e.host)
+	}
+	if e.port != "" {
+		rank++
+	}
+	return rank
+}
+
+// matches checks if the pattern matches the provided URL
+func (e urlPattern) matches(u *url.URL) bool {
+	if e.prefix {
+		if strings.ToLower(u.Scheme) != e.scheme || canonicalHost(u) != e.host {
+			return false
+		}
+		return pathHasPrefix(u.Path, e.path)
+	}
+
+	if e.port != "" && effectivePort(u) != e.port {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if e.wildcard {
+		return strings.HasSuffix(host, e.host[1:])
+	}
+	return host == e.host
+}
+
+// effectivePort returns the port of the URL, the default one of its scheme when missing
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
+
+// canonicalHost returns the lower-cased host of the URL without the
+// default port of its scheme
+func canonicalHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	port := u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		host = strings.ToLower(u.Hostname())
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return host
+}
+
+// splitHostPort is a lenient net.SplitHostPort which accepts hosts without port
+func splitHostPort(hostport string) (string, string, error) {
+	u, err := url.Parse("//" + hostport)
+	if err != nil {
+		return "", "", err
+	}
+	return u.Hostname(), u.Port(), nil
+}
+
+// pathHasPrefix checks if the path starts with the prefix on a segment boundary
+func pathHasPrefix(path, prefix string) bool {
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -0,0 +1,280 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticCredentials_Credential(t *testing.T) {
+	t.Parallel()
+
+	p := NewStaticBasicCredentials("u", "p")
+
+	result, err := p.Credential(context.Background(), &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{basicAuthScheme, "dTpw"}, result)
+}
+
+func TestEnvCredentials_Credential(t *testing.T) {
+	p := NewEnvCredentials(bearerAuthScheme, "GO_HTTP_CLIENT_TEST_TOKEN")
+
+	_, err := p.Credential(context.Background(), &url.URL{})
+	assert.NotNil(t, err)
+
+	_ = os.Setenv("GO_HTTP_CLIENT_TEST_TOKEN", "token1")
+	defer os.Unsetenv("GO_HTTP_CLIENT_TEST_TOKEN")
+
+	result, err := p.Credential(context.Background(), &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token1"}, result)
+
+	_ = os.Setenv("GO_HTTP_CLIENT_TEST_TOKEN", "token2")
+
+	result, err = p.Credential(context.Background(), &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token2"}, result)
+}
+
+func TestFileCredentials_Credential(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	p := NewFileCredentials(bearerAuthScheme, path)
+
+	_, err := p.Credential(context.Background(), &url.URL{})
+	assert.NotNil(t, err)
+
+	err = ioutil.WriteFile(path, []byte("token1\n"), 0600)
+	assert.Nil(t, err)
+
+	result, err := p.Credential(context.Background(), &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token1"}, result)
+
+	// rotate the secret
+	err = ioutil.WriteFile(path, []byte("token2\n"), 0600)
+	assert.Nil(t, err)
+	err = os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+
+	result, err = p.Credential(context.Background(), &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token2"}, result)
+}
+
+func TestNetrcCredentials_Credential(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".netrc")
+	err := ioutil.WriteFile(path, []byte(`
+# comment
+machine api.one.local login u password p
+machine api.two.local
+	login user2
+	password pass2
+
+macdef init
+machine ignored.local login x password y
+
+`), 0600)
+	assert.Nil(t, err)
+
+	p := NewNetrcCredentials(path)
+
+	result, err := p.Credential(context.Background(), &url.URL{Host: "API.one.local:8443"})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{basicAuthScheme, "dTpw"}, result)
+
+	result, err = p.Credential(context.Background(), &url.URL{Host: "api.two.local"})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{basicAuthScheme, basicAuth("user2", "pass2")}, result)
+
+	result, err = p.Credential(context.Background(), &url.URL{Host: "ignored.local"})
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
+
+func Test_parseNetrc(t *testing.T) {
+	t.Parallel()
+
+	machines, def := parseNetrc("machine a.local login a password b account c\ndefault login d password e")
+	assert.Equal(t, map[string]netrcMachine{"a.local": {"a", "b"}}, machines)
+	assert.Equal(t, &netrcMachine{"d", "e"}, def)
+}
+
+func TestCredentialStore_Provider(t *testing.T) {
+	t.Parallel()
+
+	host := NewStaticCredentials(bearerAuthScheme, "host")
+	hostPort := NewStaticCredentials(bearerAuthScheme, "host-port")
+	wildcard := NewStaticCredentials(bearerAuthScheme, "wildcard")
+	wildcardPort := NewStaticCredentials(bearerAuthScheme, "wildcard-port")
+	defaultPort := NewStaticCredentials(bearerAuthScheme, "default-port")
+	prefix := NewStaticCredentials(bearerAuthScheme, "prefix")
+	longPrefix := NewStaticCredentials(bearerAuthScheme, "long-prefix")
+
+	s := NewCredentialStore()
+	assert.Nil(t, s.Add("api.local", host))
+	assert.Nil(t, s.Add("api.local:8443", hostPort))
+	assert.Nil(t, s.Add("*.example.local", wildcard))
+	assert.Nil(t, s.Add("*.example.local:8443", wildcardPort))
+	assert.Nil(t, s.Add("secure.local:443", defaultPort))
+	assert.Nil(t, s.Add("https://api.local/v1", prefix))
+	assert.Nil(t, s.Add("https://api.local:443/v1/admin/", longPrefix))
+	assert.NotNil(t, s.Add("", host))
+	assert.NotNil(t, s.Add("https:///v1", host))
+	assert.NotNil(t, s.Add("api.local:https", host))
+
+	tests := []struct {
+		url  string
+		want CredentialProvider
+	}{
+		{"http://api.local/products", host},
+		{"https://api.local:8443/products", hostPort},
+		{"https://eu.example.local/", wildcard},
+		{"https://eu.example.local:8443/", wildcardPort},
+		{"https://eu.example.local:9443/", wildcard},
+		{"https://secure.local/", defaultPort},
+		{"https://secure.local:443/", defaultPort},
+		{"http://secure.local/", nil},
+		{"https://api.local/v1", prefix},
+		{"https://api.local/v1/products", prefix},
+		{"https://api.local/v10/products", host},
+		{"http://api.local/v1/products", host},
+		{"https://api.local/v1/admin/users", longPrefix},
+		{"https://example.local/", nil},
+		{"https://other.local/v1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, s.Provider(u))
+		})
+	}
+}
+
+func TestBaseClient_WithCredentialStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mux1, u1, shutdown1 := setup()
+	defer shutdown1()
+	mux2, u2, shutdown2 := setup()
+	defer shutdown2()
+
+	var auth1, auth2 string
+	mux1.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		auth1 = r.Header.Get(authorizationHeaderKey)
+	})
+	mux2.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		auth2 = r.Header.Get(authorizationHeaderKey)
+	})
+
+	s := NewCredentialStore()
+	assert.Nil(t, s.Add(u1, NewStaticCredentials(bearerAuthScheme, "token1")))
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithCredentialStore(s)
+
+	_, err := c.Get(ctx, u1)
+	assert.Nil(t, err)
+	_, err = c.Get(ctx, u2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Bearer token1", auth1)
+	assert.Empty(t, auth2)
+
+	// the client-wide provider is used for the hosts without a store entry
+	c = c.WithCredentialProvider(NewStaticCredentials(bearerAuthScheme, "token2"))
+
+	_, err = c.Get(ctx, u2)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer token2", auth2)
+}
+
+func TestBaseClient_WithCredentialStore_failover(t *testing.T) {
+	t.Parallel()
+
+	muxA, uA, shutdownA := setup()
+	defer shutdownA()
+	muxB, uB, shutdownB := setup()
+	defer shutdownB()
+
+	var authA, authB string
+	muxA.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		authA = r.Header.Get(authorizationHeaderKey)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	muxB.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		authB = r.Header.Get(authorizationHeaderKey)
+	})
+
+	s := NewCredentialStore()
+	assert.Nil(t, s.Add(uA, NewStaticCredentials(bearerAuthScheme, "secret-for-a")))
+
+	pool, err := NewEndpointPool(RoundRobinStrategy(), uA+"/", uB+"/")
+	assert.Nil(t, err)
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(int) time.Duration { return 0 }).
+		WithCredentialStore(s).
+		WithEndpointPool(pool)
+
+	// the retry fails over to the other endpoint without the credential of the first one
+	_, err = c.Get(context.Background(), "status")
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer secret-for-a", authA)
+	assert.Empty(t, authB)
+}
+
+func TestBaseClient_WithCredentialProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var auth []string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get(authorizationHeaderKey))
+		w.Header().Set(retryAfterHeaderKey, "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	var calls int
+	p := CredentialProviderFunc(func(ctx context.Context, u *url.URL) (*Credential, error) {
+		calls++
+		if calls == 1 {
+			return &Credential{bearerAuthScheme, "old"}, nil
+		}
+		return &Credential{bearerAuthScheme, "new"}, nil
+	})
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithCredentialProvider(p)
+
+	_, err := c.Get(ctx, u)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"Bearer old", "Bearer new"}, auth)
+
+	// provider errors fail the request
+	c = c.WithCredentialProvider(NewEnvCredentials(bearerAuthScheme, "GO_HTTP_CLIENT_UNSET_VAR"))
+	_, err = c.Get(ctx, u)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,434 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// curl short options without value
+const curlShortFlags string = "sSviLkfIG"
+
+// headers left out of the curl commands, curl sets them on its own
+var curlSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// ToCurl renders the request as a curl command, masking the values matched by
+// the redactor. The auth is included once applied, i.e. after Do; use
+// BaseClient.Curl to render a request before sending it. A compressed body is
+// rendered as it was before the compression
+func (r *Request) ToCurl(redactor *Redactor) (string, error) {
+	plain := r.uncompressed()
+	body, err := plain.bodyBytes()
+	if err != nil {
+		return "", err
+	}
+	return curlCommand(plain.Method, plain.URL, plain.Header, body, redactor), nil
+}
+
+// bodyBytes returns the body of the request, leaving it readable
+func (r *Request) bodyBytes() ([]byte, error) {
+	if r.body == nil {
+		return nil, nil
+	}
+
+	if s, ok := r.body.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(r.body)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.Seek(0, io.SeekStart)
+		return b, err
+	}
+
+	// keep the consumed body for the request
+	b, err := ioutil.ReadAll(r.body)
+	if err != nil {
+		return nil, err
+	}
+	r.body = bytes.NewReader(b)
+	return b, nil
+}
+
+// Curl renders the request as a curl command, including the auth the client
+// applies to it, masking the values matched by the redactor
+func (c *BaseClient) Curl(req *Request, redactor *Redactor) (string, error) {
+	httpReq := *req.Request
+	httpReq.Header = req.Header.Clone()
+	clone := *req
+	clone.Request = &httpReq
+
+	if err := c.authenticate(&clone); err != nil {
+		return "", err
+	}
+	if c.session != nil {
+		c.session.inject(&clone)
+	}
+
+	return clone.ToCurl(redactor)
+}
+
+// ToCurl renders the request of the last attempt as a curl command, masking
+// the values matched by the redactor
+func (r *Response) ToCurl(redactor *Redactor) (string, error) {
+	if len(r.AttemptDumps) == 0 {
+		return "", errors.New("no request dump")
+	}
+
+	dump := r.AttemptDumps[len(r.AttemptDumps)-1]
+	req, body, err := readRequestDump(dump.RequestDump)
+	if err != nil {
+		return "", err
+	}
+	u := dumpURL(r.requestURL, req)
+
+	// the transport decodes the gzip responses on its own
+	if req.Header.Get("Accept-Encoding") == "gzip" {
+		req.Header.Del("Accept-Encoding")
+	}
+
+	return curlCommand(req.Method, u, req.Header, body, redactor), nil
+}
+
+// WithCurlLogging logs every attempt as a curl command at the debug level,
+// masking the values matched by the redactor, and returns the BaseClient
+func (c *BaseClient) WithCurlLogging(redactor *Redactor) *BaseClient {
+	c.curlLogging = true
+	c.curlRedactor = redactor
+	return c
+}
+
+// curlCommand renders the curl command
+func curlCommand(method string, u *url.URL, header http.Header, body []byte, redactor *Redactor) string {
+	var sb strings.Builder
+	sb.WriteString("curl")
+
+	switch method {
+	case http.MethodGet, "":
+		// curl default
+	case http.MethodHead:
+		sb.WriteString(" --head")
+	default:
+		sb.WriteString(" -X ")
+		sb.WriteString(shellQuote(method))
+	}
+
+	sb.WriteByte(' ')
+	sb.WriteString(shellQuote(redactor.URL(u)))
+
+	redacted := redactor.Header(header)
+	keys := make([]string, 0, len(redacted))
+	for k := range redacted {
+		if !curlSkippedHeaders[http.CanonicalHeaderKey(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range redacted[k] {
+			sb.WriteString(" -H ")
+			sb.WriteString(shellQuote(k + ": " + v))
+		}
+	}
+
+	if len(body) > 0 {
+		sb.WriteString(" --data-raw ")
+		sb.WriteString(shellQuote(string(redactor.Body(header.Get(contentTypeHeaderKey), body))))
+	}
+
+	return sb.String()
+}
+
+// shellQuote quotes the value for POSIX shells
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// NewRequestFromCurl parses the curl command line and creates the request
+// through NewRequest. The usual options are supported: the method, the
+// headers, the data options, the basic auth, the user agent and the cookies
+func (c *BaseClient) NewRequestFromCurl(ctx context.Context, command string) (*Request, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+	args = expandCurlFlags(args)
+
+	var method, rawURL string
+	var data []string
+	var get, hasData bool
+	header := http.Header{}
+
+	for i := 1; i < len(args); i++ {
+		name, value, hasValue := args[i], "", false
+
+		if strings.HasPrefix(name, "--") {
+			if j := strings.Index(name, "="); j > 0 {
+				name, value, hasValue = name[:j], name[j+1:], true
+			}
+		} else if strings.HasPrefix(name, "-") && len(name) > 2 {
+			name, value, hasValue = name[:2], name[2:], true
+		} else if !strings.HasPrefix(name, "-") {
+			if rawURL != "" {
+				return nil, fmt.Errorf("unexpected argument %q", name)
+			}
+			rawURL = name
+			continue
+		}
+
+		// next returns the value of the option
+		next := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value of option %s", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "-X", "--request":
+			if method, err = next(); err != nil {
+				return nil, err
+			}
+		case "-H", "--header":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if err := addCurlHeader(header, v); err != nil {
+				return nil, err
+			}
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(v, "@") && name != "--data-raw" {
+				return nil, fmt.Errorf("option %s: reading the data from files is not supported", name)
+			}
+			if name == "--data-urlencode" {
+				v = curlURLEncode(v)
+			}
+			data = append(data, v)
+			hasData = true
+		case "-u", "--user":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			username, password := v, ""
+			if j := strings.Index(v, ":"); j >= 0 {
+				username, password = v[:j], v[j+1:]
+			}
+			header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", basicAuthScheme, basicAuth(username, password)))
+		case "-A", "--user-agent":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			header.Set(userAgentHeaderKey, v)
+		case "-b", "--cookie":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			header.Add("Cookie", v)
+		case "-e", "--referer":
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			header.Set("Referer", v)
+		case "--url":
+			if rawURL, err = next(); err != nil {
+				return nil, err
+			}
+		case "-I", "--head":
+			method = http.MethodHead
+		case "-G", "--get":
+			get = true
+		case "-s", "--silent", "-S", "--show-error", "-v", "--verbose", "-i", "--include",
+			"-L", "--location", "-k", "--insecure", "-f", "--fail", "--compressed":
+			// output and transport options, not part of the request
+		default:
+			return nil, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+
+	if rawURL == "" {
+		return nil, errors.New("missing URL")
+	}
+
+	body := strings.Join(data, "&")
+	if get && hasData {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		rawURL += sep + body
+		hasData = false
+	}
+
+	if method == "" {
+		method = http.MethodGet
+		if hasData {
+			method = http.MethodPost
+		}
+	}
+
+	var rawBody interface{}
+	if hasData {
+		rawBody = strings.NewReader(body)
+		if header.Get(contentTypeHeaderKey) == "" {
+			header.Set(contentTypeHeaderKey, formContentType)
+		}
+	}
+
+	req, err := c.NewRequest(ctx, method, rawURL, rawBody)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	return req, nil
+}
+
+// expandCurlFlags splits the grouped short flags, e.g. "-sSL"
+func expandCurlFlags(args []string) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.Trim(arg[1:], curlShortFlags) == "" {
+			for _, f := range arg[1:] {
+				result = append(result, "-"+string(f))
+			}
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+// addCurlHeader adds the "Name: value" header
+func addCurlHeader(header http.Header, v string) error {
+	// "Name;" sends the header with an empty value
+	if strings.HasSuffix(v, ";") && !strings.Contains(v, ":") {
+		header.Add(strings.TrimSuffix(v, ";"), "")
+		return nil
+	}
+
+	j := strings.Index(v, ":")
+	if j <= 0 {
+		return fmt.Errorf("invalid header %q", v)
+	}
+	name, value := strings.TrimSpace(v[:j]), strings.TrimSpace(v[j+1:])
+	if value == "" {
+		// "Name:" removes the header in curl
+		header.Del(name)
+		return nil
+	}
+	header.Add(name, value)
+	return nil
+}
+
+// curlURLEncode encodes the value of --data-urlencode: "content", "=content"
+// or "name=content"
+func curlURLEncode(v string) string {
+	j := strings.Index(v, "=")
+	switch {
+	case j < 0:
+		return url.QueryEscape(v)
+	case j == 0:
+		return url.QueryEscape(v[1:])
+	}
+	return v[:j] + "=" + url.QueryEscape(v[j+1:])
+}
+
+// splitShellWords splits the command line like a POSIX shell, handling the
+// single and the double quotes, the escapes and the line continuations
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var inWord bool
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("unterminated escape")
+			}
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+			word.WriteByte(s[i])
+			inWord = true
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
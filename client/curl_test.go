@@ -0,0 +1,207 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_shellQuote(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "https://api.local/path", shellQuote("https://api.local/path"))
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, "'a b'", shellQuote("a b"))
+	assert.Equal(t, `'it'\''s $HOME'`, shellQuote("it's $HOME"))
+}
+
+func Test_splitShellWords(t *testing.T) {
+	t.Parallel()
+
+	words, err := splitShellWords("curl -H 'A: b c' \"x \\\"y\\\" \\$z\" a\\ b \\\n  'it'\\''s'")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"curl", "-H", "A: b c", `x "y" $z`, "a b", "it's"}, words)
+
+	for _, s := range []string{"curl 'a", `curl "a`, `curl a\`} {
+		_, err := splitShellWords(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestRequest_ToCurl(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient(logrus.New())
+
+	req, err := c.NewRequest(context.Background(), http.MethodPost, "https://api.local/users?access_token=x",
+		url.Values{"name": {"John's"}, "password": {"secret"}})
+	assert.Nil(t, err)
+	req.SetHeader(contentTypeHeaderKey, formContentType)
+
+	cmd, err := req.ToCurl(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST 'https://api.local/users?access_token=x' -H 'Content-Type: application/x-www-form-urlencoded' --data-raw 'name=John%27s&password=secret'`, cmd)
+
+	// the body is still readable
+	b, err := req.bodyBytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John%27s&password=secret", string(b))
+
+	cmd, err = c.WithBearerAuth("token").Curl(req, NewRedactor())
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST 'https://api.local/users?access_token=REDACTED' -H 'Authorization: REDACTED' -H 'Content-Type: application/x-www-form-urlencoded' --data-raw 'name=John%27s&password=REDACTED'`, cmd)
+
+	cmd, err = c.Curl(req, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, cmd, `-H 'Authorization: Bearer token'`)
+
+	// the request isn't changed by Curl
+	assert.Empty(t, req.Header.Get(authorizationHeaderKey))
+
+	head, _ := c.NewRequest(context.Background(), http.MethodHead, "https://api.local", nil)
+	cmd, err = head.ToCurl(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "curl --head https://api.local", cmd)
+}
+
+func TestResponse_ToCurl(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	c := NewClient(logrus.New()).
+		WithBasicAuth("john", "pass").
+		WithRequestIDGenerator(func() (string, error) { return "id", nil })
+
+	resp, err := c.Post(context.Background(), u+"/items", jsonContentType, map[string]string{"a": "b"})
+	assert.Nil(t, err)
+
+	cmd, err := resp.ToCurl(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST `+u+`/items -H 'Authorization: Basic am9objpwYXNz' -H 'Content-Type: application/json' -H 'User-Agent: go-http-client' -H 'X-Attempt: 1' -H 'X-Request-Id: id' --data-raw '{"a":"b"}'`, cmd)
+
+	_, err = (&Response{}).ToCurl(nil)
+	assert.NotNil(t, err)
+}
+
+func TestBaseClient_WithCurlLogging(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int
+	var bodies []string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	c := NewClient(logger).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithBearerAuth("token").
+		WithRequestIDHeader("").
+		WithCurlLogging(NewRedactor())
+
+	_, err := c.Put(context.Background(), u, "text/plain", strings.NewReader("payload"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"payload", "payload"}, bodies)
+
+	var commands []string
+	for _, e := range hook.AllEntries() {
+		if i := strings.Index(e.Message, ": curl "); i >= 0 {
+			commands = append(commands, e.Message[i+2:])
+		}
+	}
+	assert.Len(t, commands, 2)
+	assert.Equal(t, `curl -X PUT `+u+` -H 'Authorization: REDACTED' -H 'Content-Type: text/plain' -H 'User-Agent: go-http-client' --data-raw payload`, commands[1])
+}
+
+func TestBaseClient_NewRequestFromCurl(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := NewClient(logrus.New())
+
+	req, err := c.NewRequestFromCurl(ctx, `curl -sSL -X PUT 'https://api.local/items/1' \
+  -H 'Content-Type: application/json' -H "X-Trace: a b" \
+  --data-raw '{"name":"it'\''s"}' -u john:pass --compressed`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.Equal(t, "https://api.local/items/1", req.URL.String())
+	assert.Equal(t, jsonContentType, req.Header.Get(contentTypeHeaderKey))
+	assert.Equal(t, "a b", req.Header.Get("X-Trace"))
+	assert.Equal(t, "Basic am9objpwYXNz", req.Header.Get(authorizationHeaderKey))
+	b, err := req.bodyBytes()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"it's"}`, string(b))
+
+	// data implies POST and the form content type
+	req, err = c.NewRequestFromCurl(ctx, `curl https://api.local/login -d user=john --data-urlencode 'note=a b&c' -A agent -b 'sid=1'`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, formContentType, req.Header.Get(contentTypeHeaderKey))
+	assert.Equal(t, "agent", req.Header.Get(userAgentHeaderKey))
+	assert.Equal(t, "sid=1", req.Header.Get("Cookie"))
+	b, _ = req.bodyBytes()
+	assert.Equal(t, "user=john&note=a+b%26c", string(b))
+
+	// -G moves the data to the query
+	req, err = c.NewRequestFromCurl(ctx, `curl -G --url=https://api.local/search?x=1 -d q=go`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "https://api.local/search?x=1&q=go", req.URL.String())
+
+	req, err = c.NewRequestFromCurl(ctx, `curl -I https://api.local -XPATCH`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPatch, req.Method)
+
+	// round trip
+	req, _ = c.NewRequest(ctx, http.MethodPost, "https://api.local/a b?q=1", map[string]string{"k": "v w"})
+	req.SetHeader(contentTypeHeaderKey, jsonContentType)
+	cmd, _ := req.ToCurl(nil)
+	parsed, err := c.NewRequestFromCurl(ctx, cmd)
+	assert.Nil(t, err)
+	assert.Equal(t, req.Method, parsed.Method)
+	assert.Equal(t, req.URL.String(), parsed.URL.String())
+	assert.Equal(t, req.Header, parsed.Header)
+	b, _ = parsed.bodyBytes()
+	assert.Equal(t, `{"k":"v w"}`, string(b))
+
+	for _, cmd := range []string{
+		"",
+		"wget https://api.local",
+		"curl",
+		"curl -H",
+		"curl https://api.local -H invalid",
+		"curl https://api.local -d @file.json",
+		"curl https://api.local --unknown",
+		"curl https://api.local https://other.local",
+		"curl 'https://api.local",
+	} {
+		_, err := c.NewRequestFromCurl(ctx, cmd)
+		assert.NotNil(t, err, cmd)
+	}
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Link relations announcing the retirement of an endpoint
+const (
+	deprecationLinkRel string = "deprecation"
+	sunsetLinkRel      string = "sunset"
+)
+
+// Deprecation holds the retirement announcement of an endpoint, parsed from
+// the Deprecation, Sunset (RFC 8594) and Link headers of the response
+type Deprecation struct {
+	// Deprecated is true when the Deprecation header is set
+	Deprecated bool `json:"deprecated"`
+
+	// Date is the deprecation date, zero when the header doesn't hold one
+	Date time.Time `json:"date"`
+
+	// Sunset is the date the endpoint stops responding, zero when not announced
+	Sunset time.Time `json:"sunset"`
+
+	// Link points to the deprecation documentation
+	Link string `json:"link,omitempty"`
+
+	// SunsetLink points to the sunset policy
+	SunsetLink string `json:"sunset_link,omitempty"`
+}
+
+// parseDeprecation returns the retirement announcement of the headers, nil
+// when there is none. The links are resolved against the request URL
+func parseDeprecation(h http.Header, base *url.URL) *Deprecation {
+	deprecation := strings.TrimSpace(h.Get(deprecationHeaderKey))
+	sunset := strings.TrimSpace(h.Get(sunsetHeaderKey))
+	if deprecation == "" && sunset == "" {
+		return nil
+	}
+
+	d := &Deprecation{}
+	if deprecation != "" && !strings.EqualFold(deprecation, "false") {
+		d.Deprecated = true
+		d.Date = parseDeprecationDate(deprecation)
+	}
+	if sunset != "" {
+		d.Sunset, _ = http.ParseTime(sunset)
+	}
+
+	for _, l := range parseLinks(h.Values(linkHeaderKey)) {
+		target := l.target
+		if u, err := url.Parse(target); err == nil && base != nil {
+			target = base.ResolveReference(u).String()
+		}
+		for _, rel := range l.rels {
+			switch {
+			case rel == deprecationLinkRel && d.Link == "":
+				d.Link = target
+			case rel == sunsetLinkRel && d.SunsetLink == "":
+				d.SunsetLink = target
+			}
+		}
+	}
+
+	if !d.Deprecated && d.Sunset.IsZero() {
+		return nil
+	}
+	return d
+}
+
+// parseDeprecationDate returns the date of the Deprecation header, either a
+// structured field date ("@1688169599") or, in the earlier drafts, a HTTP-date
+func parseDeprecationDate(v string) time.Time {
+	if strings.HasPrefix(v, "@") {
+		if sec, err := strconv.ParseInt(v[1:], 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+		return time.Time{}
+	}
+	t, _ := http.ParseTime(v)
+	return t
+}
+
+// link is a parsed Link header value (RFC 8288)
+type link struct {
+	target string
+	rels   []string
+}
+
+// parseLinks parses the Link header values, e.g. `<https://api/doc>; rel="deprecation"`
+func parseLinks(values []string) []link {
+	var links []link
+
+	for _, v := range values {
+		for v != "" {
+			v = strings.TrimLeft(v, " \t,")
+			if !strings.HasPrefix(v, "<") {
+				break
+			}
+			end := strings.IndexByte(v, '>')
+			if end < 0 {
+				break
+			}
+
+			l := link{target: v[1:end]}
+			v = v[end+1:]
+
+			// parameters until the next link
+			for {
+				v = strings.TrimLeft(v, " \t")
+				if !strings.HasPrefix(v, ";") {
+					break
+				}
+
+				var name, value string
+				name, value, v = parseLinkParam(v[1:])
+				if name == "rel" && l.rels == nil {
+					l.rels = strings.Fields(strings.ToLower(value))
+				}
+			}
+			links = append(links, l)
+		}
+	}
+
+	return links
+}
+
+// parseLinkParam parses the parameter at the start of s and returns its
+// lowercased name, its unquoted value and the rest of s
+func parseLinkParam(s string) (string, string, string) {
+	s = strings.TrimLeft(s, " \t")
+	end := strings.IndexAny(s, "=;,")
+	if end < 0 {
+		return strings.ToLower(strings.TrimSpace(s)), "", ""
+	}
+	name := strings.ToLower(strings.TrimSpace(s[:end]))
+	if s[end] != '=' {
+		return name, "", s[end:]
+	}
+
+	s = strings.TrimLeft(s[end+1:], " \t")
+	if !strings.HasPrefix(s, `"`) {
+		end = strings.IndexAny(s, ";,")
+		if end < 0 {
+			return name, strings.TrimSpace(s), ""
+		}
+		return name, strings.TrimSpace(s[:end]), s[end:]
+	}
+
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return name, value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return name, value.String(), ""
+}
+
+// DeprecationCallback is called the first time an endpoint is seen deprecated.
+// The endpoint is the method followed by the URL without its query
+type DeprecationCallback func(endpoint string, d Deprecation)
+
+// DeprecationReportEntry is the report line of a deprecated endpoint
+type DeprecationReportEntry struct {
+	Endpoint string `json:"endpoint"`
+	Deprecation
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     uint64    `json:"count"`
+}
+
+// DeprecationTracker keeps an in-memory report of the deprecated endpoints
+// called by the client
+type DeprecationTracker struct {
+	callback DeprecationCallback
+	now      func() time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*DeprecationReportEntry
+}
+
+// NewDeprecationTracker creates a new DeprecationTracker
+func NewDeprecationTracker() *DeprecationTracker {
+	return &DeprecationTracker{
+		now:       time.Now,
+		endpoints: make(map[string]*DeprecationReportEntry),
+	}
+}
+
+// WithCallback sets the function called the first time an endpoint is seen
+// deprecated and returns the DeprecationTracker
+func (t *DeprecationTracker) WithCallback(f DeprecationCallback) *DeprecationTracker {
+	t.callback = f
+	return t
+}
+
+// WithDeprecationTracker sets the deprecation tracker and returns the BaseClient
+func (c *BaseClient) WithDeprecationTracker(t *DeprecationTracker) *BaseClient {
+	c.deprecationTracker = t
+	return c
+}
+
+// observe records the deprecated endpoint, the latest announcement wins
+func (t *DeprecationTracker) observe(method string, u *url.URL, d *Deprecation) {
+	endpoint := method + " " + (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath}).String()
+	now := t.now()
+
+	t.mu.Lock()
+	entry, seen := t.endpoints[endpoint]
+	if !seen {
+		entry = &DeprecationReportEntry{Endpoint: endpoint, FirstSeen: now}
+		t.endpoints[endpoint] = entry
+	}
+	entry.Deprecation = *d
+	entry.LastSeen = now
+	entry.Count++
+	t.mu.Unlock()
+
+	if !seen && t.callback != nil {
+		t.callback(endpoint, *d)
+	}
+}
+
+// Report returns the deprecated endpoints, sorted by endpoint
+func (t *DeprecationTracker) Report() []DeprecationReportEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := make([]DeprecationReportEntry, 0, len(t.endpoints))
+	for _, entry := range t.endpoints {
+		report = append(report, *entry)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Endpoint < report[j].Endpoint
+	})
+
+	return report
+}
+
+// Handler returns a http.Handler serving the report as JSON
+func (t *DeprecationTracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		_ = json.NewEncoder(w).Encode(t.Report())
+	})
+}
@@ -0,0 +1,127 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseLinks(t *testing.T) {
+	t.Parallel()
+
+	links := parseLinks([]string{
+		`<https://api.local/doc?a=1,2>; rel="deprecation alternate"; type="text/html", <https://api.local/sunset>;rel=sunset`,
+		`<https://api.local/next>; title="a \"quoted\"; title"; rel=next`,
+		`invalid`,
+	})
+	assert.Equal(t, []link{
+		{target: "https://api.local/doc?a=1,2", rels: []string{"deprecation", "alternate"}},
+		{target: "https://api.local/sunset", rels: []string{"sunset"}},
+		{target: "https://api.local/next", rels: []string{"next"}},
+	}, links)
+}
+
+func Test_parseDeprecation(t *testing.T) {
+	t.Parallel()
+
+	base, _ := url.Parse("https://api.local/v1/orders")
+	sunset := time.Date(2027, time.January, 31, 23, 59, 59, 0, time.UTC)
+
+	assert.Nil(t, parseDeprecation(http.Header{}, base))
+	assert.Nil(t, parseDeprecation(http.Header{deprecationHeaderKey: {"false"}}, base))
+
+	d := parseDeprecation(http.Header{
+		deprecationHeaderKey: {"@1688169599"},
+		sunsetHeaderKey:      {sunset.Format(http.TimeFormat)},
+		linkHeaderKey:        {`</docs/v2>; rel="deprecation", <https://api.local/policy>; rel="sunset"`},
+	}, base)
+	assert.Equal(t, &Deprecation{
+		Deprecated: true,
+		Date:       time.Unix(1688169599, 0).UTC(),
+		Sunset:     sunset,
+		Link:       "https://api.local/docs/v2",
+		SunsetLink: "https://api.local/policy",
+	}, d)
+
+	// earlier drafts
+	d = parseDeprecation(http.Header{deprecationHeaderKey: {"true"}}, base)
+	assert.True(t, d.Deprecated)
+	assert.True(t, d.Date.IsZero())
+
+	d = parseDeprecation(http.Header{deprecationHeaderKey: {sunset.Format(http.TimeFormat)}}, base)
+	assert.Equal(t, sunset, d.Date)
+
+	// sunset only
+	d = parseDeprecation(http.Header{sunsetHeaderKey: {sunset.Format(http.TimeFormat)}}, base)
+	assert.False(t, d.Deprecated)
+	assert.Equal(t, sunset, d.Sunset)
+}
+
+func TestBaseClient_WithDeprecationTracker(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(deprecationHeaderKey, "@1688169599")
+		w.Header().Set(sunsetHeaderKey, "Sun, 31 Jan 2027 23:59:59 GMT")
+		w.Header().Set(linkHeaderKey, `<https://api.local/docs/v2>; rel="deprecation"`)
+	})
+	mux.HandleFunc("/v2/orders", func(w http.ResponseWriter, r *http.Request) {})
+
+	var mu sync.Mutex
+	var seen []string
+	tracker := NewDeprecationTracker().WithCallback(func(endpoint string, d Deprecation) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "https://api.local/docs/v2", d.Link)
+		seen = append(seen, endpoint)
+	})
+	c := NewClient(logrus.New()).WithDeprecationTracker(tracker)
+
+	for _, path := range []string{"/v1/orders?page=1", "/v1/orders?page=2", "/v1/users", "/v2/orders"} {
+		resp, err := c.Get(context.Background(), u+path)
+		assert.Nil(t, err)
+		if path == "/v2/orders" {
+			assert.Nil(t, resp.Deprecation)
+		} else {
+			assert.True(t, resp.Deprecation.Deprecated)
+		}
+	}
+	_, err := c.Post(context.Background(), u+"/v1/orders", jsonContentType, nil)
+	assert.Nil(t, err)
+
+	mu.Lock()
+	assert.Equal(t, []string{"GET " + u + "/v1/orders", "GET " + u + "/v1/users", "POST " + u + "/v1/orders"}, seen)
+	mu.Unlock()
+
+	report := tracker.Report()
+	assert.Len(t, report, 3)
+	assert.Equal(t, "GET "+u+"/v1/orders", report[0].Endpoint)
+	assert.Equal(t, uint64(2), report[0].Count)
+	assert.Equal(t, time.Date(2027, time.January, 31, 23, 59, 59, 0, time.UTC), report[0].Sunset)
+	assert.False(t, report[0].LastSeen.Before(report[0].FirstSeen))
+
+	// debug endpoint
+	rec := httptest.NewRecorder()
+	tracker.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/deprecations", nil))
+	assert.Equal(t, jsonContentType, rec.Header().Get(contentTypeHeaderKey))
+
+	var served []DeprecationReportEntry
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	assert.Len(t, served, 3)
+	assert.Equal(t, "https://api.local/docs/v2", served[2].Link)
+}
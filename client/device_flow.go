@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuth 2.0 device authorization grant (RFC 8628) constants
+const (
+	deviceCodeGrantType   string = "urn:ietf:params:oauth:grant-type:device_code"
+	refreshTokenGrantType string = "refresh_token"
+
+	defaultDevicePollInterval int = 5
+	slowDownIntervalIncrease  int = 5
+)
+
+// DeviceAuthorization is the response of the device authorization endpoint
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// DevicePromptFunc surfaces the verification URI and the user code to the user
+type DevicePromptFunc func(ctx context.Context, auth *DeviceAuthorization) error
+
+// DeviceFlowConfig holds the configuration of the device authorization grant
+type DeviceFlowConfig struct {
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// DeviceAuthorizationURL is the device authorization endpoint
+	DeviceAuthorizationURL string
+
+	// TokenURL is the token endpoint
+	TokenURL string
+
+	// Prompt is called with the verification URI and the user code
+	Prompt DevicePromptFunc
+}
+
+// OAuthError is an error response of the token endpoint
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Error returns the error message
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2: %s", e.Code)
+}
+
+// DeviceFlow runs the OAuth 2.0 device authorization grant (RFC 8628).
+// It is an OAuthTokenSource, so it can feed a RefreshableBearerAuthenticator
+type DeviceFlow struct {
+	client *BaseClient
+	cfg    DeviceFlowConfig
+
+	// the unit of the polling interval and of the expiry, a second as defined by the RFC
+	intervalUnit time.Duration
+
+	now func() time.Time
+}
+
+// NewDeviceFlow creates a new DeviceFlow which sends its requests with the provided client.
+// The requests of the flow never carry the client auth
+func NewDeviceFlow(c *BaseClient, cfg DeviceFlowConfig) *DeviceFlow {
+	return &DeviceFlow{
+		client:       c,
+		cfg:          cfg,
+		intervalUnit: time.Second,
+		now:          time.Now,
+	}
+}
+
+// Token requests a device code, prompts the user and polls the token
+// endpoint until the user completes the authorization
+func (f *DeviceFlow) Token(ctx context.Context) (*OAuthToken, error) {
+	auth, err := f.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.cfg.Prompt == nil {
+		return nil, fmt.Errorf("device flow: no prompt configured")
+	}
+	if err := f.cfg.Prompt(ctx, auth); err != nil {
+		return nil, err
+	}
+
+	return f.poll(ctx, auth)
+}
+
+// Refresh exchanges the refresh token for a new token
+func (f *DeviceFlow) Refresh(ctx context.Context, token *OAuthToken) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {refreshTokenGrantType},
+		"refresh_token": {token.RefreshToken},
+	}
+	return f.token(ctx, form)
+}
+
+// authorize requests the device and user codes
+func (f *DeviceFlow) authorize(ctx context.Context) (*DeviceAuthorization, error) {
+	form := url.Values{"client_id": {f.cfg.ClientID}}
+	if len(f.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(f.cfg.Scopes, " "))
+	}
+
+	resp, err := f.post(ctx, f.cfg.DeviceAuthorizationURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.GetStatusCode() != http.StatusOK {
+		return nil, oauthResponseError(resp)
+	}
+
+	var auth DeviceAuthorization
+	if err := resp.UnmarshalJSONResponse(&auth); err != nil {
+		return nil, err
+	}
+	if auth.DeviceCode == "" || auth.VerificationURI == "" {
+		return nil, fmt.Errorf("device flow: invalid device authorization response")
+	}
+	if auth.Interval <= 0 {
+		auth.Interval = defaultDevicePollInterval
+	}
+
+	return &auth, nil
+}
+
+// poll polls the token endpoint honoring "authorization_pending" and "slow_down"
+func (f *DeviceFlow) poll(ctx context.Context, auth *DeviceAuthorization) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {auth.DeviceCode},
+	}
+
+	interval := auth.Interval
+
+	var deadline <-chan time.Time
+	if auth.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(auth.ExpiresIn) * f.intervalUnit)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, &OAuthError{Code: "expired_token", Description: "the device code expired before the user authorized it"}
+		case <-time.After(time.Duration(interval) * f.intervalUnit):
+		}
+
+		token, err := f.token(ctx, form)
+		if err == nil {
+			return token, nil
+		}
+
+		oauthErr, ok := err.(*OAuthError)
+		if !ok {
+			return nil, err
+		}
+
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIntervalIncrease
+		default:
+			return nil, err
+		}
+	}
+}
+
+// token calls the token endpoint
+func (f *DeviceFlow) token(ctx context.Context, form url.Values) (*OAuthToken, error) {
+	form.Set("client_id", f.cfg.ClientID)
+
+	resp, err := f.post(ctx, f.cfg.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.GetStatusCode() != http.StatusOK {
+		return nil, oauthResponseError(resp)
+	}
+
+	var token OAuthToken
+	if err := resp.UnmarshalJSONResponse(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("device flow: no access token in the token response")
+	}
+	token.setExpiry(f.now())
+
+	return &token, nil
+}
+
+// post sends the form to the endpoint
+func (f *DeviceFlow) post(ctx context.Context, endpoint string, form url.Values) (*Response, error) {
+	req, err := f.client.NewRequest(ctx, http.MethodPost, endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetHeaders(map[string]string{
+		contentTypeHeaderKey: formContentType,
+		acceptHeaderKey:      jsonContentType,
+		userAgentHeaderKey:   userAgentHeaderValue,
+	})
+	if f.cfg.ClientSecret != "" {
+		req.SetHeader(authorizationHeaderKey, basicAuthScheme+" "+basicAuth(url.QueryEscape(f.cfg.ClientID), url.QueryEscape(f.cfg.ClientSecret)))
+	}
+	req.skipAuth = true
+
+	return f.client.Do(req)
+}
+
+// oauthResponseError builds the error of a failed OAuth response
+func oauthResponseError(resp *Response) error {
+	b, err := resp.GetBody()
+	if err != nil {
+		return err
+	}
+
+	var oauthErr OAuthError
+	if err := json.Unmarshal(b, &oauthErr); err != nil || oauthErr.Code == "" {
+		return fmt.Errorf("oauth2: unexpected HTTP status %s", resp.GetStatus())
+	}
+	return &oauthErr
+}
@@ -0,0 +1,243 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAuthServer is a minimal RFC 8628 authorization server
+type fakeAuthServer struct {
+	mu       sync.Mutex
+	polls    []string
+	pending  int
+	slowDown bool
+	deny     bool
+	refresh  int
+}
+
+func (s *fakeAuthServer) register(mux *http.ServeMux) {
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("client_id") != "cli" || r.Header.Get(authorizationHeaderKey) != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"device_code":"dc","user_code":"ABCD-EFGH","verification_uri":"https://auth.local/device","expires_in":600,"interval":1}`)
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_ = r.ParseForm()
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+
+		switch r.Form.Get("grant_type") {
+		case refreshTokenGrantType:
+			s.refresh++
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": fmt.Sprintf("refreshed-%d", s.refresh),
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+			return
+		case deviceCodeGrantType:
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"unsupported_grant_type"}`)
+			return
+		}
+
+		s.polls = append(s.polls, r.Form.Get("device_code"))
+
+		switch {
+		case s.deny:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"access_denied","error_description":"the user denied the request"}`)
+		case s.pending > 0:
+			s.pending--
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"authorization_pending"}`)
+		case s.slowDown:
+			s.slowDown = false
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"slow_down"}`)
+		default:
+			_, _ = fmt.Fprint(w, `{"access_token":"at","token_type":"Bearer","refresh_token":"rt","expires_in":3600}`)
+		}
+	})
+}
+
+func newTestDeviceFlow(c *BaseClient, u string, prompt DevicePromptFunc) *DeviceFlow {
+	f := NewDeviceFlow(c, DeviceFlowConfig{
+		ClientID:               "cli",
+		Scopes:                 []string{"read", "write"},
+		DeviceAuthorizationURL: u + "/device",
+		TokenURL:               u + "/token",
+		Prompt:                 prompt,
+	})
+	f.intervalUnit = time.Millisecond
+	return f
+}
+
+func TestDeviceFlow_Token(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		server := &fakeAuthServer{pending: 2, slowDown: true}
+		server.register(mux)
+
+		var prompted *DeviceAuthorization
+		c := NewClient(logrus.New()).WithRetryMax(0).WithBearerAuth("must-not-leak")
+		f := newTestDeviceFlow(c, u, func(ctx context.Context, auth *DeviceAuthorization) error {
+			prompted = auth
+			return nil
+		})
+
+		now := time.Unix(1600000000, 0)
+		f.now = func() time.Time { return now }
+
+		token, err := f.Token(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, &OAuthToken{
+			AccessToken:  "at",
+			TokenType:    "Bearer",
+			RefreshToken: "rt",
+			ExpiresIn:    3600,
+			Expiry:       now.Add(time.Hour),
+		}, token)
+
+		assert.Equal(t, "ABCD-EFGH", prompted.UserCode)
+		assert.Equal(t, "https://auth.local/device", prompted.VerificationURI)
+		assert.Equal(t, []string{"dc", "dc", "dc", "dc"}, server.polls)
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		server := &fakeAuthServer{deny: true}
+		server.register(mux)
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		f := newTestDeviceFlow(c, u, func(ctx context.Context, auth *DeviceAuthorization) error {
+			return nil
+		})
+
+		_, err := f.Token(ctx)
+		assert.Equal(t, &OAuthError{Code: "access_denied", Description: "the user denied the request"}, err)
+	})
+
+	t.Run("prompt error", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		server := &fakeAuthServer{}
+		server.register(mux)
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		f := newTestDeviceFlow(c, u, func(ctx context.Context, auth *DeviceAuthorization) error {
+			return fmt.Errorf("no terminal")
+		})
+
+		_, err := f.Token(ctx)
+		assert.EqualError(t, err, "no terminal")
+		assert.Empty(t, server.polls)
+
+		f = newTestDeviceFlow(c, u, nil)
+		_, err = f.Token(ctx)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		server := &fakeAuthServer{pending: 1000}
+		server.register(mux)
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		f := newTestDeviceFlow(c, u, func(ctx context.Context, auth *DeviceAuthorization) error {
+			return nil
+		})
+
+		cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		_, err := f.Token(cctx)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestDeviceFlow_RefreshableBearerAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	server := &fakeAuthServer{pending: 1}
+	server.register(mux)
+
+	var auth []string
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get(authorizationHeaderKey))
+	})
+
+	var prompts int
+	c := NewClient(logrus.New()).WithRetryMax(0)
+	f := newTestDeviceFlow(c, u, func(ctx context.Context, auth *DeviceAuthorization) error {
+		prompts++
+		return nil
+	})
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	a := NewRefreshableBearerAuthenticator(f).WithTokenCache(NewFileTokenCache(path))
+
+	// the same client is used for the flow and the API calls
+	c = c.WithCredentialProvider(a)
+
+	_, err := c.Get(ctx, u+"/api")
+	assert.Nil(t, err)
+	_, err = c.Get(ctx, u+"/api")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Bearer at", "Bearer at"}, auth)
+	assert.Equal(t, 1, prompts)
+
+	// a new run uses the cached token
+	cached := NewRefreshableBearerAuthenticator(f).WithTokenCache(NewFileTokenCache(path))
+	token, err := cached.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "at", token.AccessToken)
+	assert.Equal(t, 1, prompts)
+
+	// the expired token is refreshed and the refresh token is kept
+	cached.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	token, err = cached.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "refreshed-1", token.AccessToken)
+	assert.Equal(t, "rt", token.RefreshToken)
+	assert.Equal(t, 1, prompts)
+
+	saved, err := NewFileTokenCache(path).Load()
+	assert.Nil(t, err)
+	assert.Equal(t, "refreshed-1", saved.AccessToken)
+}
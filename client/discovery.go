@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoEndpointsDiscovered is returned when a discovery finds no endpoint,
+// the pool keeps its endpoints
+var ErrNoEndpointsDiscovered = errors.New("no endpoints discovered")
+
+// Discoverer finds the current endpoints of a service
+type Discoverer interface {
+	Discover(ctx context.Context) ([]*Endpoint, error)
+}
+
+// DiscoveryErrorHook is called when a periodic discovery fails
+type DiscoveryErrorHook func(err error)
+
+// Refresh replaces the endpoints of the pool by the discovered ones, see SetEndpoints
+func (p *EndpointPool) Refresh(ctx context.Context, d Discoverer) error {
+	endpoints, err := d.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return ErrNoEndpointsDiscovered
+	}
+
+	p.SetEndpoints(endpoints)
+	return nil
+}
+
+// StartDiscovery refreshes the endpoints of the pool now and then at every
+// interval until the context is done. A failed refresh keeps the endpoints and
+// is reported to the hook, which can be nil; only the first one is returned
+func (p *EndpointPool) StartDiscovery(ctx context.Context, d Discoverer, interval time.Duration, hook DiscoveryErrorHook) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid discovery interval %s", interval)
+	}
+	if err := p.Refresh(ctx, d); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Refresh(ctx, d); err != nil && hook != nil && ctx.Err() == nil {
+					hook(err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SRVResolver looks up the SRV records of a service, *net.Resolver implements
+// it, e.g. the one of NewDNSServerResolver
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVDiscoverer discovers the endpoints of the SRV records of a service. The
+// priority and the weight of the records are kept
+type SRVDiscoverer struct {
+	resolver SRVResolver
+
+	service string
+	proto   string
+	name    string
+
+	scheme string
+	path   string
+}
+
+// NewSRVDiscoverer creates a discoverer of the _service._proto.name records,
+// the base URLs are scheme://target:port/path. The resolver is the system one when nil
+func NewSRVDiscoverer(r SRVResolver, service, proto, name, scheme, path string) *SRVDiscoverer {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return &SRVDiscoverer{resolver: r, service: service, proto: proto, name: name, scheme: scheme, path: path}
+}
+
+// Discover looks up the SRV records
+func (d *SRVDiscoverer) Discover(ctx context.Context) ([]*Endpoint, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, d.service, d.proto, d.name)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]*Endpoint, 0, len(records))
+	for _, r := range records {
+		// "." announces the service isn't available at this domain, RFC 2782
+		target := strings.TrimSuffix(r.Target, ".")
+		if target == "" {
+			continue
+		}
+
+		baseURL := d.scheme + "://" + net.JoinHostPort(target, strconv.Itoa(int(r.Port))) + d.path
+		e, err := NewEndpoint(baseURL, int(r.Priority), int(r.Weight))
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	return endpoints, nil
+}
+
+// fileEndpoint is an endpoint of the registry file
+type fileEndpoint struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+}
+
+// FileDiscoverer discovers the endpoints listed in a JSON registry file:
+//
+//	[{"url": "http://10.0.1.10:8080/api/", "priority": 0, "weight": 10}]
+type FileDiscoverer struct {
+	path string
+}
+
+// NewFileDiscoverer creates a discoverer of the registry file, read at every discovery
+func NewFileDiscoverer(path string) *FileDiscoverer {
+	return &FileDiscoverer{path: path}
+}
+
+// Discover reads the registry file
+func (d *FileDiscoverer) Discover(_ context.Context) ([]*Endpoint, error) {
+	b, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fileEndpoint
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding the registry %s: %w", d.path, err)
+	}
+
+	endpoints := make([]*Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		e, err := NewEndpoint(entry.URL, entry.Priority, entry.Weight)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	return endpoints, nil
+}
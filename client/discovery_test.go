@@ -0,0 +1,155 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRVDiscoverer_Discover(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	})
+
+	server, _ := url.Parse(u)
+	port, _ := strconv.Atoi(server.Port())
+
+	dns := newFakeDNSServer(t, nil)
+	defer dns.conn.Close()
+	dns.setSRV("_api._tcp.svc.test",
+		&net.SRV{Target: "backup.test.", Port: uint16(port), Priority: 20, Weight: 5},
+		&net.SRV{Target: "primary.test.", Port: uint16(port), Priority: 10, Weight: 1},
+	)
+
+	d := NewSRVDiscoverer(NewDNSServerResolver(dns.conn.LocalAddr().String()), "api", "tcp", "svc.test", "http", "/v1/")
+	endpoints, err := d.Discover(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, endpoints, 2)
+
+	pool, err := NewEndpointPool(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, pool.Refresh(context.Background(), d))
+
+	byHost := make(map[string]*Endpoint)
+	for _, e := range pool.Endpoints() {
+		byHost[e.URL.Hostname()] = e
+	}
+	assert.Equal(t, "http://primary.test:"+server.Port()+"/v1/", byHost["primary.test"].URL.String())
+	assert.Equal(t, 10, byHost["primary.test"].Priority)
+	assert.Equal(t, 1, byHost["primary.test"].Weight)
+	assert.Equal(t, 20, byHost["backup.test"].Priority)
+	assert.Equal(t, 5, byHost["backup.test"].Weight)
+
+	c := NewClient(logrus.New()).
+		WithHostOverride("primary.test:*", "127.0.0.1").
+		WithHostOverride("backup.test:*", "127.0.0.1").
+		WithEndpointPool(pool)
+
+	// the lowest priority gets the requests
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(context.Background(), "status")
+		assert.Nil(t, err)
+		body, _ := resp.GetStringBody()
+		assert.Equal(t, "primary.test:"+server.Port(), body)
+	}
+
+	// the next priority takes over while the preferred one is ejected
+	pool.WithOutlierDetection(1, time.Minute)
+	pool.acquire(byHost["primary.test"])
+	pool.release(byHost["primary.test"], false)
+
+	resp, err := c.Get(context.Background(), "status")
+	assert.Nil(t, err)
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "backup.test:"+server.Port(), body)
+
+	// no records
+	dns.setSRV("_api._tcp.svc.test")
+	assert.NotNil(t, pool.Refresh(context.Background(), d))
+	assert.Len(t, pool.Endpoints(), 2)
+}
+
+func TestEndpointPool_StartDiscovery(t *testing.T) {
+	t.Parallel()
+
+	registry := filepath.Join(t.TempDir(), "registry.json")
+	write := func(content string) {
+		assert.Nil(t, ioutil.WriteFile(registry, []byte(content), 0600))
+	}
+	write(`[{"url": "http://a.local/"}, {"url": "http://b.local/", "weight": 2}]`)
+
+	pool, err := NewEndpointPool(nil)
+	assert.Nil(t, err)
+
+	var failures int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = pool.StartDiscovery(ctx, NewFileDiscoverer(registry), 10*time.Millisecond, func(err error) {
+		atomic.AddInt32(&failures, 1)
+	})
+	assert.Nil(t, err)
+	assert.Len(t, pool.Endpoints(), 2)
+
+	// a request in flight to an endpoint kept by the update
+	a := pool.Endpoints()[0]
+	pool.acquire(a)
+
+	waitEndpoints := func(n int) []*Endpoint {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if endpoints := pool.Endpoints(); len(endpoints) == n {
+				return endpoints
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("the pool doesn't have %d endpoints", n)
+		return nil
+	}
+
+	write(`[{"url": "http://a.local/", "priority": 1}, {"url": "http://b.local/"}, {"url": "http://c.local/"}]`)
+	endpoints := waitEndpoints(3)
+	assert.True(t, endpoints[0] == a)
+	assert.Equal(t, 1, a.Priority)
+	assert.Equal(t, int64(1), a.Outstanding())
+	pool.release(a, true)
+	assert.Equal(t, int64(0), a.Outstanding())
+
+	// a broken registry keeps the endpoints
+	write(`not json`)
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&failures) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, atomic.LoadInt32(&failures) > 0)
+	assert.Len(t, pool.Endpoints(), 3)
+
+	write(`[{"url": "http://c.local/"}]`)
+	endpoints = waitEndpoints(1)
+	assert.Equal(t, "http://c.local/", endpoints[0].URL.String())
+
+	// the first discovery failure is returned
+	other, _ := NewEndpointPool(nil)
+	assert.NotNil(t, other.StartDiscovery(ctx, NewFileDiscoverer(filepath.Join(t.TempDir(), "missing.json")), time.Second, nil))
+
+	// the interval is checked before the first discovery
+	assert.NotNil(t, other.StartDiscovery(ctx, NewFileDiscoverer(registry), 0, nil))
+	assert.Empty(t, other.Endpoints())
+}
@@ -0,0 +1,330 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNS record types of the DNS-over-HTTPS JSON API
+const (
+	dnsTypeA    int = 1
+	dnsTypeAAAA int = 28
+
+	dnsStatusNXDomain int    = 3
+	dnsJSONType       string = "application/dns-json"
+)
+
+// Resolver looks up the IP addresses of a host, *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// hostDialer dials the connections of the client, resolving the hosts with
+// the static overrides first and the resolver next
+type hostDialer struct {
+	dialer *net.Dialer
+
+	mu        sync.RWMutex
+	overrides map[string][]string
+	resolver  Resolver
+}
+
+// WithHostOverride resolves the host and port to the addresses, like the
+// curl --resolve option, and returns the BaseClient. The port "*" matches
+// every port. The TLS server name and the Host header keep the original host
+func (c *BaseClient) WithHostOverride(hostPort string, addrs ...string) *BaseClient {
+	d := c.hostDialer()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(addrs) == 0 {
+		delete(d.overrides, strings.ToLower(hostPort))
+		return c
+	}
+	d.overrides[strings.ToLower(hostPort)] = addrs
+	return c
+}
+
+// WithResolver sets the resolver of the hosts without override, and returns
+// the BaseClient. See NewDNSServerResolver, NewDoHResolver and NewDNSCache
+func (c *BaseClient) WithResolver(r Resolver) *BaseClient {
+	d := c.hostDialer()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.resolver = r
+	return c
+}
+
+// hostDialer returns the dialer of the client, installing it in the transport
+func (c *BaseClient) hostDialer() *hostDialer {
+	if c.dialer != nil {
+		return c.dialer
+	}
+
+	c.dialer = &hostDialer{
+		dialer: &net.Dialer{
+			Timeout:   dialContextTimeout,
+			KeepAlive: dialContextKeepAlive,
+		},
+		overrides: make(map[string][]string),
+	}
+	if c.hc == nil {
+		c.hc = getHTTPClient()
+	}
+	if t, ok := c.hc.Transport.(*http.Transport); ok {
+		t.DialContext = c.dialer.DialContext
+	}
+	return c.dialer
+}
+
+// DialContext connects to the address, trying its IP addresses in order
+func (d *hostDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.lookup(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	var dialErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, dialErr
+}
+
+// lookup returns the addresses of the host, nil when the default resolution applies
+func (d *hostDialer) lookup(ctx context.Context, host, port string) ([]string, error) {
+	d.mu.RLock()
+	addrs, ok := d.overrides[strings.ToLower(net.JoinHostPort(host, port))]
+	if !ok {
+		addrs, ok = d.overrides[strings.ToLower(net.JoinHostPort(host, "*"))]
+	}
+	resolver := d.resolver
+	d.mu.RUnlock()
+
+	if ok {
+		return addrs, nil
+	}
+	if resolver == nil || net.ParseIP(host) != nil {
+		return nil, nil
+	}
+
+	// keep the DNS timing of the attempt
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ipAddrs, err := resolver.LookupIPAddr(ctx, host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ipAddrs, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ipAddrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs = make([]string, 0, len(ipAddrs))
+	for _, a := range ipAddrs {
+		addrs = append(addrs, a.String())
+	}
+	return addrs, nil
+}
+
+// NewDNSServerResolver returns a resolver querying the DNS server at the
+// address, e.g. "10.0.0.2:53", instead of the ones of the system
+func NewDNSServerResolver(address string) *net.Resolver {
+	dialer := &net.Dialer{Timeout: dialContextTimeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// dohResponse is the answer of the DNS-over-HTTPS JSON API
+type dohResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+// DoHResolver resolves the hosts with the DNS-over-HTTPS JSON API, as served
+// by Cloudflare and Google
+type DoHResolver struct {
+	client   *BaseClient
+	endpoint string
+}
+
+// NewDoHResolver creates a resolver querying the endpoint, e.g.
+// "https://cloudflare-dns.com/dns-query", with the client. The client must not
+// use the resolver itself
+func NewDoHResolver(c *BaseClient, endpoint string) *DoHResolver {
+	return &DoHResolver{client: c, endpoint: endpoint}
+}
+
+// LookupIPAddr looks up the IPv4 and IPv6 addresses of the host
+func (r *DoHResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	var notFound bool
+	for _, t := range []int{dnsTypeA, dnsTypeAAAA} {
+		answer, err := r.query(ctx, host, t)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: host, Server: r.endpoint}
+		}
+		if answer.Status == dnsStatusNXDomain {
+			notFound = true
+			break
+		}
+		if answer.Status != 0 {
+			return nil, &net.DNSError{Err: fmt.Sprintf("DNS status %d", answer.Status), Name: host, Server: r.endpoint}
+		}
+		for _, a := range answer.Answer {
+			if a.Type != t {
+				continue
+			}
+			if ip := net.ParseIP(a.Data); ip != nil {
+				addrs = append(addrs, net.IPAddr{IP: ip})
+			}
+		}
+	}
+
+	if notFound || len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, Server: r.endpoint, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// query queries the records of the type
+func (r *DoHResolver) query(ctx context.Context, host string, recordType int) (*dohResponse, error) {
+	u, err := url.Parse(r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("name", host)
+	q.Set("type", fmt.Sprint(recordType))
+	u.RawQuery = q.Encode()
+
+	req, err := r.client.NewRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(acceptHeaderKey, dnsJSONType)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetStatusCode() != http.StatusOK {
+		_ = drainBody(resp.RawResponse.Body, r.client.drainLimit)
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.GetStatus())
+	}
+
+	var answer dohResponse
+	if err := resp.UnmarshalJSONResponse(&answer); err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// dnsCacheEntry holds the result of a lookup until it expires
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCache caches the lookups of a resolver. The host not found errors are
+// cached for the negative TTL, the other errors aren't cached
+type DNSCache struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+// NewDNSCache creates a cache of the resolver, the system one when nil
+func NewDNSCache(r Resolver, ttl, negativeTTL time.Duration) *DNSCache {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return &DNSCache{
+		resolver:    r,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]dnsCacheEntry),
+	}
+}
+
+// LookupIPAddr returns the cached addresses of the host, looking them up when expired
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := strings.ToLower(host)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.addrs, e.err
+	}
+
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+
+	var ttl time.Duration
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		ttl = c.ttl
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		ttl = c.negativeTTL
+	}
+
+	c.mu.Lock()
+	if ttl > 0 {
+		c.entries[key] = dnsCacheEntry{addrs: addrs, err: err, expires: time.Now().Add(ttl)}
+	} else {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	return addrs, err
+}
+
+// Flush removes every cached lookup
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]dnsCacheEntry)
+}
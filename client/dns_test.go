@@ -0,0 +1,286 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDNSServer answers the A and SRV queries of its records over UDP
+type fakeDNSServer struct {
+	conn    net.PacketConn
+	records map[string]net.IP
+
+	mu      sync.Mutex
+	srv     map[string][]*net.SRV
+	queries map[string]int
+}
+
+// newFakeDNSServer starts a DNS server answering the records
+func newFakeDNSServer(t *testing.T, records map[string]net.IP) *fakeDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeDNSServer{conn: conn, records: records, srv: make(map[string][]*net.SRV), queries: make(map[string]int)}
+	go s.serve()
+	return s
+}
+
+// serve answers the queries until the server is closed
+func (s *fakeDNSServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if answer := s.answer(buf[:n]); answer != nil {
+			_, _ = s.conn.WriteTo(answer, addr)
+		}
+	}
+}
+
+// answer builds the response of the query
+func (s *fakeDNSServer) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// the question: the labels of the name, its type and class
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		l := int(query[i])
+		if i+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+l]))
+		i += 1 + l
+	}
+	end := i + 5
+	if end > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[i+1:])
+
+	s.mu.Lock()
+	s.queries[name]++
+	srv, isSRV := s.srv[name]
+	s.mu.Unlock()
+
+	ip, ok := s.records[name]
+	rcode := uint16(0)
+	if !ok && !isSRV {
+		rcode = 3
+	}
+
+	resp := make([]byte, 12, 512)
+	copy(resp, query[:2])
+	binary.BigEndian.PutUint16(resp[2:], 0x8180|binary.BigEndian.Uint16(query[2:])&0x0100|rcode)
+	binary.BigEndian.PutUint16(resp[4:], 1)
+	resp = append(resp, query[12:end]...)
+
+	if ok && qtype == uint16(dnsTypeA) {
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		resp = append(resp, ip.To4()...)
+	}
+	if isSRV && qtype == 33 {
+		binary.BigEndian.PutUint16(resp[6:], uint16(len(srv)))
+		for _, r := range srv {
+			target := encodeDNSName(r.Target)
+			resp = append(resp, 0xc0, 12, 0, 33, 0, 1, 0, 0, 0, 60)
+			resp = append(resp, byte((6+len(target))>>8), byte(6+len(target)))
+			resp = append(resp, byte(r.Priority>>8), byte(r.Priority), byte(r.Weight>>8), byte(r.Weight), byte(r.Port>>8), byte(r.Port))
+			resp = append(resp, target...)
+		}
+	}
+	return resp
+}
+
+// encodeDNSName encodes the name as DNS labels
+func encodeDNSName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// setSRV sets the SRV records of the name
+func (s *fakeDNSServer) setSRV(name string, records ...*net.SRV) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.srv[name] = records
+}
+
+// count returns the number of queries of the name
+func (s *fakeDNSServer) count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queries[name]
+}
+
+func TestBaseClient_WithHostOverride(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	})
+
+	server, _ := url.Parse(u)
+	port := server.Port()
+
+	// the first address refuses the connection
+	c := NewClient(logrus.New()).WithHostOverride("api.test:"+port, "127.0.0.2", "127.0.0.1")
+
+	resp, err := c.Get(context.Background(), "http://api.test:"+port)
+	assert.Nil(t, err)
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "api.test:"+port, body)
+
+	// the wildcard port
+	c = NewClient(logrus.New()).WithHostOverride("other.test:*", "127.0.0.1")
+	resp, err = c.Get(context.Background(), "http://other.test:"+port)
+	assert.Nil(t, err)
+	body, _ = resp.GetStringBody()
+	assert.Equal(t, "other.test:"+port, body)
+
+	// removing the override
+	c.WithRetryMax(0).WithHostOverride("other.test:*")
+	c.hc.CloseIdleConnections()
+	_, err = c.Get(context.Background(), "http://other.test:"+port)
+	assert.NotNil(t, err)
+}
+
+func TestNewDNSServerResolver(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("resolved"))
+	})
+
+	dns := newFakeDNSServer(t, map[string]net.IP{"api.test": net.ParseIP("127.0.0.1")})
+	defer dns.conn.Close()
+
+	cache := NewDNSCache(NewDNSServerResolver(dns.conn.LocalAddr().String()), time.Minute, time.Minute)
+	c := NewClient(logrus.New()).WithRetryMax(0).WithResolver(cache)
+
+	server, _ := url.Parse(u)
+	resp, err := c.Get(context.Background(), "http://api.test:"+server.Port())
+	assert.Nil(t, err)
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "resolved", body)
+	assert.True(t, resp.Timings[0].DNSLookup > 0)
+
+	// the lookups are cached
+	queries := dns.count("api.test")
+	assert.True(t, queries > 0)
+	addrs, err := cache.LookupIPAddr(context.Background(), "api.test")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", addrs[0].String())
+	assert.Equal(t, queries, dns.count("api.test"))
+
+	// the unknown hosts too
+	_, err = c.Get(context.Background(), "http://missing.test:"+server.Port())
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+
+	queries = dns.count("missing.test")
+	_, err = cache.LookupIPAddr(context.Background(), "missing.test")
+	assert.NotNil(t, err)
+	assert.Equal(t, queries, dns.count("missing.test"))
+
+	// a flush looks the hosts up again
+	cache.Flush()
+	_, err = cache.LookupIPAddr(context.Background(), "api.test")
+	assert.Nil(t, err)
+	assert.True(t, dns.count("api.test") > queries)
+}
+
+func TestDNSCache_expiry(t *testing.T) {
+	t.Parallel()
+
+	dns := newFakeDNSServer(t, map[string]net.IP{"api.test": net.ParseIP("10.0.0.1")})
+	defer dns.conn.Close()
+
+	cache := NewDNSCache(NewDNSServerResolver(dns.conn.LocalAddr().String()), 20*time.Millisecond, 0)
+
+	_, err := cache.LookupIPAddr(context.Background(), "api.test")
+	assert.Nil(t, err)
+	queries := dns.count("api.test")
+
+	time.Sleep(50 * time.Millisecond)
+	_, err = cache.LookupIPAddr(context.Background(), "api.test")
+	assert.Nil(t, err)
+	assert.True(t, dns.count("api.test") > queries)
+
+	// no negative caching without a negative TTL
+	_, err = cache.LookupIPAddr(context.Background(), "missing.test")
+	assert.NotNil(t, err)
+	queries = dns.count("missing.test")
+	_, err = cache.LookupIPAddr(context.Background(), "missing.test")
+	assert.NotNil(t, err)
+	assert.True(t, dns.count("missing.test") > queries)
+}
+
+func TestDoHResolver_LookupIPAddr(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/dns-query", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, dnsJSONType, r.Header.Get(acceptHeaderKey))
+		w.Header().Set(contentTypeHeaderKey, dnsJSONType)
+
+		q := r.URL.Query()
+		switch {
+		case q.Get("name") != "api.test":
+			_, _ = w.Write([]byte(`{"Status":3}`))
+		case q.Get("type") == "1":
+			_, _ = w.Write([]byte(`{"Status":0,"Answer":[{"name":"api.test","type":5,"data":"alias.test."},{"name":"alias.test","type":1,"data":"10.0.0.1"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"Status":0,"Answer":[{"name":"api.test","type":28,"data":"fd00::1"}]}`))
+		}
+	})
+
+	r := NewDoHResolver(NewClient(logrus.New()), u+"/dns-query")
+
+	addrs, err := r.LookupIPAddr(context.Background(), "api.test")
+	assert.Nil(t, err)
+	assert.Len(t, addrs, 2)
+	assert.Equal(t, "10.0.0.1", addrs[0].String())
+	assert.Equal(t, "fd00::1", addrs[1].String())
+
+	_, err = r.LookupIPAddr(context.Background(), "missing.test")
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+}
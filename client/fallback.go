@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// FallbackFunc builds the response returned when a call fails after its
+// retries. The response is the one of the failed call, nil when no response
+// was received. Returning an error keeps the call failed
+type FallbackFunc func(req *Request, resp *Response, err error) (*Response, error)
+
+// fallbackEntry holds a single entry of the FallbackStore
+type fallbackEntry struct {
+	urlPattern
+
+	fallback FallbackFunc
+}
+
+// FallbackStore keeps the fallbacks keyed by host or URL prefix
+type FallbackStore struct {
+	mu      sync.RWMutex
+	entries []fallbackEntry
+}
+
+// NewFallbackStore creates a new empty FallbackStore
+func NewFallbackStore() *FallbackStore {
+	return &FallbackStore{}
+}
+
+// Add registers the fallback for the pattern, see CredentialStore.Add for
+// the patterns and their precedence
+func (s *FallbackStore) Add(pattern string, f FallbackFunc) error {
+	up, err := parseURLPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, fallbackEntry{urlPattern: up, fallback: f})
+	sort.SliceStable(s.entries, func(i, j int) bool {
+		return s.entries[i].rank() > s.entries[j].rank()
+	})
+
+	return nil
+}
+
+// Fallback returns the fallback registered for the URL or nil when there is
+// no matching pattern
+func (s *FallbackStore) Fallback(u *url.URL) FallbackFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		if e.matches(u) {
+			return e.fallback
+		}
+	}
+	return nil
+}
+
+// WithFallback sets the client-wide fallback and returns the BaseClient
+func (c *BaseClient) WithFallback(f FallbackFunc) *BaseClient {
+	c.fallback = f
+	return c
+}
+
+// WithFallbackStore sets the per-route fallbacks and returns the BaseClient.
+// A matching store entry wins over the client-wide fallback
+func (c *BaseClient) WithFallbackStore(s *FallbackStore) *BaseClient {
+	c.fallbackStore = s
+	return c
+}
+
+// WithFallback sets the fallback of the request, which wins over the ones
+// of the client, and returns the Request
+func (r *Request) WithFallback(f FallbackFunc) *Request {
+	r.fallback = f
+	return r
+}
+
+// fallbackFor returns the fallback of the request: its own, the one of the
+// matching route or the client-wide one
+func (c *BaseClient) fallbackFor(req *Request) FallbackFunc {
+	if req.fallback != nil {
+		return req.fallback
+	}
+	if c.fallbackStore != nil {
+		if f := c.fallbackStore.Fallback(req.URL); f != nil {
+			return f
+		}
+	}
+	return c.fallback
+}
+
+// applyFallback replaces the failed call by the response of its fallback,
+// marked as degraded. The failure is the error of the call, or the status of
+// the response the retries gave up on. The calls canceled by the caller aren't
+// replaced, the result of the call is kept when there is no fallback
+func (c *BaseClient) applyFallback(req *Request, resp *Response, err, failure error) (*Response, error) {
+	f := c.fallbackFor(req)
+	if f == nil || failure == nil || errors.Is(failure, context.Canceled) || req.Context().Err() != nil {
+		return resp, err
+	}
+
+	fallback, fallbackErr := f(req, resp, failure)
+	if fallbackErr != nil || fallback == nil {
+		if fallbackErr != nil {
+			c.getLogger().WithError(fallbackErr).Errorf("%s %s fallback failed", req.Method, req.URL)
+		}
+		return resp, err
+	}
+
+	c.getLogger().WithError(failure).Warnf("%s %s failed, serving the fallback response", req.Method, req.URL)
+
+	fallback.Degraded = true
+	fallback.DegradedCause = failure
+	return fallback, nil
+}
+
+// NewSyntheticResponse creates a response of the request which wasn't received
+// from the server, e.g. in a FallbackFunc
+func NewSyntheticResponse(req *Request, status int, header http.Header, body []byte) *Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &Response{
+		RawResponse: &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req.Request,
+		},
+		requestURL: req.URL,
+	}
+}
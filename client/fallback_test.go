@@ -0,0 +1,151 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticFallback returns a fallback answering with the body
+func staticFallback(body string) FallbackFunc {
+	return func(req *Request, _ *Response, _ error) (*Response, error) {
+		return NewSyntheticResponse(req, http.StatusOK, http.Header{contentTypeHeaderKey: {jsonContentType}}, []byte(body)), nil
+	}
+}
+
+func TestFallbackStore_Fallback(t *testing.T) {
+	t.Parallel()
+
+	s := NewFallbackStore()
+	assert.Nil(t, s.Add("api.local", staticFallback("host")))
+	assert.Nil(t, s.Add("https://api.local/v2", staticFallback("prefix")))
+	assert.NotNil(t, s.Add("", staticFallback("invalid")))
+
+	u, _ := url.Parse("https://api.local/v2/orders")
+	assert.NotNil(t, s.Fallback(u))
+
+	u, _ = url.Parse("https://other.local/v2/orders")
+	assert.Nil(t, s.Fallback(u))
+}
+
+func TestBaseClient_WithFallback(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	routes := NewFallbackStore()
+	assert.Nil(t, routes.Add(u+"/orders", staticFallback(`"route"`)))
+
+	var received *Response
+	var receivedErr error
+	c := NewClient(logrus.New()).
+		WithRetryMax(0).
+		WithFallbackStore(routes).
+		WithFallback(func(req *Request, resp *Response, err error) (*Response, error) {
+			received, receivedErr = resp, err
+			return NewSyntheticResponse(req, http.StatusOK, nil, []byte(`"client"`)), nil
+		})
+
+	get := func(path string, f FallbackFunc) (string, *Response) {
+		req, err := c.NewRequest(context.Background(), http.MethodGet, u+path, nil)
+		assert.Nil(t, err)
+		if f != nil {
+			req.WithFallback(f)
+		}
+		resp, err := c.Do(req)
+		assert.Nil(t, err)
+		assert.True(t, resp.Degraded)
+		assert.Contains(t, resp.DegradedCause.Error(), "503")
+
+		var body string
+		assert.Nil(t, resp.UnmarshalJSONResponse(&body))
+		return body, resp
+	}
+
+	body, resp := get("/users", nil)
+	assert.Equal(t, "client", body)
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	assert.Equal(t, http.StatusServiceUnavailable, received.GetStatusCode())
+	assert.NotNil(t, receivedErr)
+
+	body, _ = get("/orders/1", nil)
+	assert.Equal(t, "route", body)
+
+	body, _ = get("/orders/1", staticFallback(`"request"`))
+	assert.Equal(t, "request", body)
+
+	// the fallback can give up
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u+"/users", nil)
+	assert.Nil(t, err)
+	resp, err = c.Do(req.WithFallback(func(_ *Request, _ *Response, _ error) (*Response, error) {
+		return nil, errors.New("no fallback")
+	}))
+	assert.NotNil(t, err)
+	assert.False(t, resp.Degraded)
+
+	// the canceled calls aren't replaced
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx, u+"/users")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestBaseClient_WithFallback_gaveUp(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(int) time.Duration { return 0 })
+
+	// the 429 the retries gave up on isn't an error
+	resp, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.GetStatusCode())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// but it is replaced by the fallback
+	resp, err = c.WithFallback(staticFallback(`"fallback"`)).Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.True(t, resp.Degraded)
+	assert.Contains(t, resp.DegradedCause.Error(), "429")
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestNewSyntheticResponse(t *testing.T) {
+	t.Parallel()
+
+	req, err := NewClient(logrus.New()).NewRequest(context.Background(), http.MethodGet, "https://api.local/", nil)
+	assert.Nil(t, err)
+
+	resp := NewSyntheticResponse(req, http.StatusAccepted, nil, []byte("ok"))
+	assert.Equal(t, "202 Accepted", resp.GetStatus())
+	assert.NotNil(t, resp.GetHeaders())
+	body, err := resp.GetStringBody()
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", body)
+}
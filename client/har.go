@@ -0,0 +1,448 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// harVersion is the version of the HTTP Archive format
+const harVersion string = "1.2"
+
+// HAR is an HTTP Archive, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of the archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is the application which created the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request/response pair
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest is the request of an entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is the response of an entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header or a query string parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is a request or a response cookie
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// HARPostData is the body of a request
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []HARNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+}
+
+// HARContent is the body of a response
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings is the timing breakdown of an entry, in milliseconds.
+// -1 means the phase doesn't apply
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// total returns the sum of the applying phases, as the time of the entry.
+// The SSL time is already included in the connect time
+func (t HARTimings) total() float64 {
+	var total float64
+	for _, v := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+// NewHAR creates a new HAR holding the entries
+func NewHAR(entries []HAREntry) *HAR {
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return &HAR{Log: HARLog{
+		Version: harVersion,
+		Creator: HARCreator{Name: userAgentHeaderValue, Version: harVersion},
+		Entries: entries,
+	}}
+}
+
+// HAREntries converts every attempt of the response to a HAR entry, masking
+// the values matched by the redactor
+func (r *Response) HAREntries(redactor *Redactor) ([]HAREntry, error) {
+	entries := make([]HAREntry, 0, len(r.AttemptDumps))
+	for i, dump := range r.AttemptDumps {
+		var timing AttemptTiming
+		if i < len(r.Timings) {
+			timing = r.Timings[i]
+		}
+
+		entry, err := newHAREntry(r.requestURL, dump, timing, redactor)
+		if err != nil {
+			return nil, fmt.Errorf("attempt %d: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// HAR returns the response, including every attempt, as an HTTP Archive
+func (r *Response) HAR(redactor *Redactor) (*HAR, error) {
+	entries, err := r.HAREntries(redactor)
+	if err != nil {
+		return nil, err
+	}
+	return NewHAR(entries), nil
+}
+
+// newHAREntry creates the HAR entry of an attempt from its dump
+func newHAREntry(u *url.URL, dump *DataDump, timing AttemptTiming, redactor *Redactor) (HAREntry, error) {
+	entry := HAREntry{
+		StartedDateTime: timing.Start.Format(time.RFC3339Nano),
+		Timings:         harTimings(timing),
+		ServerIPAddress: hostOnly(timing.RemoteAddr),
+	}
+	entry.Time = entry.Timings.total()
+	if _, port, err := net.SplitHostPort(timing.RemoteAddr); err == nil {
+		entry.Connection = port
+	}
+
+	req, reqBody, err := readRequestDump(dump.RequestDump)
+	if err != nil {
+		return entry, err
+	}
+	reqURL := dumpURL(u, req)
+	entry.Request = newHARRequest(req, reqURL, reqBody, redactor)
+
+	if dump.ResponseDump == nil {
+		entry.Response = HARResponse{
+			Cookies: []HARCookie{},
+			Headers: []HARNameValue{},
+		}
+		entry.Comment = fmt.Sprintf("attempt %d: no response", timing.Attempt)
+		return entry, nil
+	}
+
+	resp, respBody, err := readResponseDump(dump.ResponseDump, req)
+	if err != nil {
+		return entry, err
+	}
+	entry.Response = newHARResponse(resp, respBody, redactor)
+	entry.Comment = fmt.Sprintf("attempt %d", timing.Attempt)
+
+	return entry, nil
+}
+
+// newHARRequest converts the request
+func newHARRequest(req *http.Request, u *url.URL, body []byte, redactor *Redactor) HARRequest {
+	result := HARRequest{
+		Method:      req.Method,
+		URL:         redactor.URL(u),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies(), redactor.redactsHeader("Cookie")),
+		Headers:     harHeaders(redactor.Header(req.Header)),
+		QueryString: harValues(redactor.Values(u.Query())),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	if len(body) > 0 {
+		contentType := req.Header.Get(contentTypeHeaderKey)
+		body = redactor.Body(contentType, body)
+		result.PostData = &HARPostData{MimeType: contentType, Text: string(body)}
+
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == formContentType {
+			if v, err := url.ParseQuery(string(body)); err == nil {
+				result.PostData.Params = harValues(v)
+			}
+		}
+	}
+
+	return result
+}
+
+// newHARResponse converts the response
+func newHARResponse(resp *http.Response, body []byte, redactor *Redactor) HARResponse {
+	contentType := resp.Header.Get(contentTypeHeaderKey)
+
+	result := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies(), redactor.redactsHeader("Set-Cookie")),
+		Headers:     harHeaders(redactor.Header(resp.Header)),
+		Content:     HARContent{Size: len(body), MimeType: contentType},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	body = redactor.Body(contentType, body)
+	if utf8.Valid(body) {
+		result.Content.Text = string(body)
+	} else {
+		result.Content.Text = base64.StdEncoding.EncodeToString(body)
+		result.Content.Encoding = "base64"
+	}
+
+	return result
+}
+
+// harTimings converts the timing of the attempt. The HAR connect time includes the TLS handshake
+func harTimings(t AttemptTiming) HARTimings {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	optional := func(d time.Duration) float64 {
+		if d == 0 {
+			return -1
+		}
+		return ms(d)
+	}
+
+	result := HARTimings{
+		DNS:     optional(t.DNSLookup),
+		Connect: optional(t.Connect + t.TLSHandshake),
+		SSL:     optional(t.TLSHandshake),
+		Wait:    ms(t.ServerProcessing),
+		Receive: ms(between(t.Start.Add(t.TimeToFirstByte), t.Start.Add(t.Total))),
+	}
+
+	blocked := t.TimeToFirstByte - t.DNSLookup - t.Connect - t.TLSHandshake - t.ServerProcessing
+	if blocked > 0 {
+		result.Blocked = ms(blocked)
+	}
+
+	return result
+}
+
+// harHeaders converts the header, sorted by name
+func harHeaders(h http.Header) []HARNameValue {
+	result := []HARNameValue{}
+	for _, k := range sortedHeaderKeys(h) {
+		for _, v := range h[k] {
+			result = append(result, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return result
+}
+
+// harValues converts the query string or the form values, sorted by name
+func harValues(v url.Values) []HARNameValue {
+	return harHeaders(http.Header(v))
+}
+
+// harCookies converts the cookies, masking their values when requested
+func harCookies(cookies []*http.Cookie, redact bool) []HARCookie {
+	result := []HARCookie{}
+	for _, c := range cookies {
+		hc := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if redact {
+			hc.Value = redactedValue
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(time.RFC3339)
+		}
+		result = append(result, hc)
+	}
+	return result
+}
+
+// sortedHeaderKeys returns the keys of the header in order
+func sortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hostOnly returns the host of the "host:port" address
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// HARRecorder records the calls of a client session as HAR entries
+type HARRecorder struct {
+	redactor *Redactor
+
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+// NewHARRecorder creates a new HARRecorder masking the values matched by the
+// redactor. A nil redactor records the values as they are
+func NewHARRecorder(redactor *Redactor) *HARRecorder {
+	return &HARRecorder{redactor: redactor}
+}
+
+// WithHARRecorder sets the HAR recorder and returns the BaseClient. Every
+// attempt of every call is recorded, including the failed ones
+func (c *BaseClient) WithHARRecorder(r *HARRecorder) *BaseClient {
+	c.harRecorder = r
+	return c
+}
+
+// WithAttemptBodyDumps buffers the response body of every attempt in the
+// AttemptDumps, and returns the BaseClient. Only the body of the final attempt
+// is buffered otherwise, unless a HAR recorder or an audit sink is set
+func (c *BaseClient) WithAttemptBodyDumps() *BaseClient {
+	c.attemptBodyDumps = true
+	return c
+}
+
+// dumpsAttemptBodies checks if the response body of every attempt is buffered
+func (c *BaseClient) dumpsAttemptBodies() bool {
+	return c.attemptBodyDumps || c.harRecorder != nil || c.auditSink != nil
+}
+
+// dumpResponseBody dumps the response with its body, which is buffered. A body
+// exceeding its size limit is returned as error
+func dumpResponseBody(dump *DataDump, resp *http.Response) error {
+	var err error
+	dump.ResponseDump, err = httputil.DumpResponse(resp, true)
+	if errors.Is(err, ErrDecodedBodyTooLarge) || errors.Is(err, ErrBodyTooLarge) {
+		return err
+	}
+	return nil
+}
+
+// Record adds the attempts of the response to the recorded entries
+func (r *HARRecorder) Record(resp *Response) error {
+	entries, err := resp.HAREntries(r.redactor)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entries...)
+	return nil
+}
+
+// HAR returns the recorded entries as an HTTP Archive
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return NewHAR(append([]HAREntry(nil), r.entries...))
+}
+
+// Reset drops the recorded entries
+func (r *HARRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = nil
+}
+
+// WriteTo writes the recorded entries as HAR JSON
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// WriteFile writes the recorded entries to the .har file, atomically
+func (r *HARRecorder) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0600)
+}
@@ -0,0 +1,185 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_harTimings(t *testing.T) {
+	t.Parallel()
+
+	result := harTimings(AttemptTiming{
+		DNSLookup:        time.Millisecond,
+		Connect:          2 * time.Millisecond,
+		TLSHandshake:     3 * time.Millisecond,
+		ServerProcessing: 10 * time.Millisecond,
+		TimeToFirstByte:  20 * time.Millisecond,
+		Total:            25 * time.Millisecond,
+	})
+	assert.Equal(t, HARTimings{Blocked: 4, DNS: 1, Connect: 5, SSL: 3, Wait: 10, Receive: 5}, result)
+
+	// reused connection
+	result = harTimings(AttemptTiming{ServerProcessing: time.Millisecond, TimeToFirstByte: time.Millisecond, Total: time.Millisecond})
+	assert.Equal(t, HARTimings{DNS: -1, Connect: -1, SSL: -1, Wait: 1}, result)
+}
+
+func TestResponse_HAR(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		_, _ = w.Write([]byte(`{"access_token":"secret","expires_in":60}`))
+	})
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithBearerAuth("token")
+
+	req, err := c.NewRequest(context.Background(), http.MethodPost, u+"/token?client_secret=secret&scope=read",
+		url.Values{"username": {"john"}, "password": {"pass"}})
+	assert.Nil(t, err)
+	req.SetHeader(contentTypeHeaderKey, formContentType)
+
+	resp, err := c.Do(req)
+	assert.Nil(t, err)
+	assert.Len(t, resp.AttemptDumps, 2)
+
+	har, err := resp.HAR(NewRedactor())
+	assert.Nil(t, err)
+	assert.Equal(t, harVersion, har.Log.Version)
+	assert.Len(t, har.Log.Entries, 2)
+
+	first, second := har.Log.Entries[0], har.Log.Entries[1]
+	assert.Equal(t, http.StatusServiceUnavailable, first.Response.Status)
+	assert.Equal(t, "attempt 1", first.Comment)
+	assert.Equal(t, http.StatusOK, second.Response.Status)
+	assert.Equal(t, "attempt 2", second.Comment)
+
+	assert.Equal(t, http.MethodPost, second.Request.Method)
+	assert.Equal(t, u+"/token?client_secret=REDACTED&scope=read", second.Request.URL)
+	assert.Contains(t, second.Request.Headers, HARNameValue{Name: authorizationHeaderKey, Value: redactedValue})
+	assert.Contains(t, second.Request.QueryString, HARNameValue{Name: "scope", Value: "read"})
+	assert.Equal(t, "password=REDACTED&username=john", second.Request.PostData.Text)
+	assert.Equal(t, []HARNameValue{{"password", redactedValue}, {"username", "john"}}, second.Request.PostData.Params)
+
+	assert.Equal(t, `{"access_token":"REDACTED","expires_in":60}`, second.Response.Content.Text)
+	assert.Equal(t, jsonContentType, second.Response.Content.MimeType)
+	assert.Equal(t, []HARCookie{{Name: "session", Value: redactedValue}}, second.Response.Cookies)
+	assert.Contains(t, second.Response.Headers, HARNameValue{Name: "Set-Cookie", Value: redactedValue})
+	assert.NotEmpty(t, second.ServerIPAddress)
+	assert.True(t, second.Time > 0)
+
+	// without redaction
+	entries, err := resp.HAREntries(nil)
+	assert.Nil(t, err)
+	assert.Contains(t, entries[1].Request.Headers, HARNameValue{Name: authorizationHeaderKey, Value: "Bearer token"})
+}
+
+func TestBaseClient_WithAttemptBodyDumps(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("unavailable"))
+			return
+		}
+		_, _ = w.Write([]byte("ready"))
+	})
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond })
+
+	// only the body of the final attempt is kept
+	resp, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Len(t, resp.AttemptDumps, 2)
+	assert.NotContains(t, string(resp.AttemptDumps[0].ResponseDump), "unavailable")
+	assert.Contains(t, string(resp.AttemptDumps[1].ResponseDump), "ready")
+	assert.Equal(t, resp.AttemptDumps[1].ResponseDump, resp.DataDump.ResponseDump)
+	assert.True(t, resp.Timings[1].Total >= resp.Timings[1].TimeToFirstByte)
+	body, _ := resp.GetStringBody()
+	assert.Equal(t, "ready", body)
+
+	// the final attempt of a failed call keeps it too
+	resp, err = c.WithRetryMax(0).Get(context.Background(), u)
+	assert.NotNil(t, err)
+	assert.Contains(t, string(resp.AttemptDumps[0].ResponseDump), "unavailable")
+
+	// every attempt keeps it when requested
+	atomic.StoreInt32(&calls, 0)
+	resp, err = c.WithRetryMax(1).WithAttemptBodyDumps().Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Contains(t, string(resp.AttemptDumps[0].ResponseDump), "unavailable")
+	assert.Contains(t, string(resp.AttemptDumps[1].ResponseDump), "ready")
+}
+
+func TestHARRecorder(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte{0xff, 0xfe})
+	})
+
+	rec := NewHARRecorder(NewRedactor())
+	c := NewClient(logrus.New()).WithRetryMax(0).WithHARRecorder(rec)
+
+	_, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+
+	// the failures are recorded too
+	_, err = c.Get(context.Background(), "http://127.0.0.1:0")
+	assert.NotNil(t, err)
+
+	har := rec.HAR()
+	assert.Len(t, har.Log.Entries, 2)
+	assert.Equal(t, "base64", har.Log.Entries[0].Response.Content.Encoding)
+	assert.Equal(t, "//4=", har.Log.Entries[0].Response.Content.Text)
+	assert.Equal(t, 0, har.Log.Entries[1].Response.Status)
+	assert.Equal(t, "attempt 1: no response", har.Log.Entries[1].Comment)
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	assert.Nil(t, rec.WriteFile(path))
+
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	var loaded HAR
+	assert.Nil(t, json.Unmarshal(b, &loaded))
+	assert.Len(t, loaded.Log.Entries, 2)
+	assert.Equal(t, u+"/", loaded.Log.Entries[0].Request.URL)
+
+	rec.Reset()
+	assert.Empty(t, rec.HAR().Log.Entries)
+}
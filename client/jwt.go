@@ -0,0 +1,310 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// JWT signing algorithms
+const (
+	JWTAlgorithmHS256 string = "HS256"
+	JWTAlgorithmRS256 string = "RS256"
+	JWTAlgorithmES256 string = "ES256"
+	JWTAlgorithmEdDSA string = "EdDSA"
+)
+
+// JWT defaults
+const (
+	defaultJWTTTL           time.Duration = 5 * time.Minute
+	defaultJWTRefreshBefore time.Duration = 30 * time.Second
+)
+
+// JWTAudienceFunc returns the "aud" claim for the request URL
+type JWTAudienceFunc func(u *url.URL) string
+
+// jwtToken holds a signed token and its expiry
+type jwtToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+// JWTAuthenticator is a CredentialProvider which mints and signs short-lived
+// JWTs used as bearer tokens. The tokens are cached per audience until
+// shortly before they expire
+type JWTAuthenticator struct {
+	algorithm string
+	key       interface{}
+	keyID     string
+
+	issuer        string
+	subject       string
+	audience      JWTAudienceFunc
+	ttl           time.Duration
+	refreshBefore time.Duration
+	claims        map[string]interface{}
+
+	now func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]jwtToken
+}
+
+// NewJWTAuthenticator creates a new JWTAuthenticator. The key must be a []byte
+// for HS256, a *rsa.PrivateKey for RS256, a P-256 *ecdsa.PrivateKey for ES256
+// and an ed25519.PrivateKey for EdDSA
+func NewJWTAuthenticator(algorithm string, key interface{}) (*JWTAuthenticator, error) {
+	if err := checkJWTKey(algorithm, key); err != nil {
+		return nil, err
+	}
+
+	return &JWTAuthenticator{
+		algorithm:     algorithm,
+		key:           key,
+		audience:      HostJWTAudience,
+		ttl:           defaultJWTTTL,
+		refreshBefore: defaultJWTRefreshBefore,
+		claims:        make(map[string]interface{}),
+		now:           time.Now,
+		tokens:        make(map[string]jwtToken),
+	}, nil
+}
+
+// HostJWTAudience is the default JWTAudienceFunc which uses the request host
+// (without the default port) as audience
+func HostJWTAudience(u *url.URL) string {
+	return canonicalHost(u)
+}
+
+// StaticJWTAudience returns a JWTAudienceFunc which always uses the provided audience
+func StaticJWTAudience(audience string) JWTAudienceFunc {
+	return func(_ *url.URL) string {
+		return audience
+	}
+}
+
+// WithKeyID sets the "kid" header and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithKeyID(keyID string) *JWTAuthenticator {
+	a.keyID = keyID
+	return a
+}
+
+// WithIssuer sets the "iss" claim and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithIssuer(issuer string) *JWTAuthenticator {
+	a.issuer = issuer
+	return a
+}
+
+// WithSubject sets the "sub" claim and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithSubject(subject string) *JWTAuthenticator {
+	a.subject = subject
+	return a
+}
+
+// WithAudience sets the func used to derive the "aud" claim and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithAudience(audience JWTAudienceFunc) *JWTAuthenticator {
+	if audience != nil {
+		a.audience = audience
+	}
+	return a
+}
+
+// WithTTL sets the lifetime of the tokens and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithTTL(ttl time.Duration) *JWTAuthenticator {
+	if ttl > 0 {
+		a.ttl = ttl
+	}
+	return a
+}
+
+// WithRefreshBefore sets how long before the expiry a cached token is
+// replaced and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithRefreshBefore(d time.Duration) *JWTAuthenticator {
+	if d >= 0 {
+		a.refreshBefore = d
+	}
+	return a
+}
+
+// WithClaim sets an additional claim and returns the JWTAuthenticator
+func (a *JWTAuthenticator) WithClaim(name string, value interface{}) *JWTAuthenticator {
+	a.claims[name] = value
+	return a
+}
+
+// Credential returns the bearer credential for the request URL, minting
+// a new token when there is no cached one or it is about to expire
+func (a *JWTAuthenticator) Credential(_ context.Context, u *url.URL) (*Credential, error) {
+	token, err := a.Token(a.audience(u))
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{bearerAuthScheme, token}, nil
+}
+
+// Token returns a signed token for the audience
+func (a *JWTAuthenticator) Token(audience string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+
+	if t, ok := a.tokens[audience]; ok && now.Before(t.expiresAt.Add(-a.refreshBefore)) {
+		return t.value, nil
+	}
+
+	t, err := a.sign(audience, now)
+	if err != nil {
+		return "", err
+	}
+	a.tokens[audience] = t
+
+	return t.value, nil
+}
+
+// sign builds and signs a new token
+func (a *JWTAuthenticator) sign(audience string, now time.Time) (jwtToken, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return jwtToken{}, err
+	}
+
+	expiresAt := now.Add(a.ttl)
+
+	claims := make(map[string]interface{}, len(a.claims)+6)
+	for k, v := range a.claims {
+		claims[k] = v
+	}
+	if a.issuer != "" {
+		claims["iss"] = a.issuer
+	}
+	if a.subject != "" {
+		claims["sub"] = a.subject
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+
+	header := map[string]string{
+		"alg": a.algorithm,
+		"typ": "JWT",
+	}
+	if a.keyID != "" {
+		header["kid"] = a.keyID
+	}
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return jwtToken{}, err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return jwtToken{}, err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	signature, err := signJWT(a.algorithm, a.key, []byte(signingInput))
+	if err != nil {
+		return jwtToken{}, err
+	}
+
+	return jwtToken{signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), expiresAt}, nil
+}
+
+// checkJWTKey checks if the key type fits the algorithm
+func checkJWTKey(algorithm string, key interface{}) error {
+	ok := false
+	switch algorithm {
+	case JWTAlgorithmHS256:
+		var k []byte
+		k, ok = key.([]byte)
+		ok = ok && len(k) > 0
+	case JWTAlgorithmRS256:
+		var k *rsa.PrivateKey
+		k, ok = key.(*rsa.PrivateKey)
+		ok = ok && k != nil && k.N != nil && k.D != nil
+	case JWTAlgorithmES256:
+		var k *ecdsa.PrivateKey
+		k, ok = key.(*ecdsa.PrivateKey)
+		ok = ok && k != nil && k.D != nil && k.Curve == elliptic.P256()
+	case JWTAlgorithmEdDSA:
+		var k ed25519.PrivateKey
+		k, ok = key.(ed25519.PrivateKey)
+		ok = ok && len(k) == ed25519.PrivateKeySize
+	default:
+		return fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+	if !ok {
+		return fmt.Errorf("invalid %T key for JWT algorithm %s", key, algorithm)
+	}
+	return nil
+}
+
+// signJWT signs the input with the key
+func signJWT(algorithm string, key interface{}, input []byte) ([]byte, error) {
+	if algorithm == JWTAlgorithmEdDSA {
+		return ed25519.Sign(key.(ed25519.PrivateKey), input), nil
+	}
+
+	digest := sha256.Sum256(input)
+
+	switch algorithm {
+	case JWTAlgorithmHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		_, _ = mac.Write(input)
+		return mac.Sum(nil), nil
+	case JWTAlgorithmRS256:
+		return rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case JWTAlgorithmES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed-size R || S encoding instead of ASN.1
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded PKCS #1, PKCS #8 or SEC 1 private key,
+// so it can be passed to NewJWTAuthenticator
+func ParsePrivateKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
@@ -0,0 +1,264 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeJWT splits the token and decodes its parts
+func decodeJWT(t *testing.T, token string) (map[string]interface{}, map[string]interface{}, []byte, []byte) {
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	var header, claims map[string]interface{}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(b, &header))
+
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(b, &claims))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+
+	return header, claims, []byte(parts[0] + "." + parts[1]), signature
+}
+
+func TestNewJWTAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	_, err := NewJWTAuthenticator("none", []byte("secret"))
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmHS256, []byte{})
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmRS256, []byte("secret"))
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmES256, p384Key)
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmES256, rsaKey)
+	assert.NotNil(t, err)
+
+	// nil and empty keys are rejected instead of panicking while signing
+	_, err = NewJWTAuthenticator(JWTAlgorithmRS256, (*rsa.PrivateKey)(nil))
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmRS256, &rsa.PrivateKey{})
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmES256, (*ecdsa.PrivateKey)(nil))
+	assert.NotNil(t, err)
+
+	_, err = NewJWTAuthenticator(JWTAlgorithmES256, &ecdsa.PrivateKey{})
+	assert.NotNil(t, err)
+
+	result, err := NewJWTAuthenticator(JWTAlgorithmRS256, rsaKey)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultJWTTTL, result.ttl)
+	assert.Equal(t, defaultJWTRefreshBefore, result.refreshBefore)
+}
+
+func TestJWTAuthenticator_Token(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	hmacKey := []byte("secret")
+
+	tests := []struct {
+		algorithm string
+		key       interface{}
+		verify    func(input, signature []byte) bool
+	}{
+		{
+			algorithm: JWTAlgorithmHS256,
+			key:       hmacKey,
+			verify: func(input, signature []byte) bool {
+				mac := hmac.New(sha256.New, hmacKey)
+				_, _ = mac.Write(input)
+				return hmac.Equal(mac.Sum(nil), signature)
+			},
+		},
+		{
+			algorithm: JWTAlgorithmRS256,
+			key:       rsaKey,
+			verify: func(input, signature []byte) bool {
+				digest := sha256.Sum256(input)
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) == nil
+			},
+		},
+		{
+			algorithm: JWTAlgorithmES256,
+			key:       ecKey,
+			verify: func(input, signature []byte) bool {
+				digest := sha256.Sum256(input)
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				return len(signature) == 64 && ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+			},
+		},
+		{
+			algorithm: JWTAlgorithmEdDSA,
+			key:       edKey,
+			verify: func(input, signature []byte) bool {
+				return ed25519.Verify(edKey.Public().(ed25519.PublicKey), input, signature)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			now := time.Unix(1600000000, 0)
+
+			a, err := NewJWTAuthenticator(tt.algorithm, tt.key)
+			assert.Nil(t, err)
+			a = a.WithKeyID("key-1").
+				WithIssuer("svc").
+				WithSubject("svc-user").
+				WithTTL(time.Minute).
+				WithClaim("scope", "read")
+			a.now = func() time.Time { return now }
+
+			token, err := a.Token("api.local")
+			assert.Nil(t, err)
+
+			header, claims, input, signature := decodeJWT(t, token)
+			assert.Equal(t, map[string]interface{}{"alg": tt.algorithm, "typ": "JWT", "kid": "key-1"}, header)
+			assert.Equal(t, "svc", claims["iss"])
+			assert.Equal(t, "svc-user", claims["sub"])
+			assert.Equal(t, "api.local", claims["aud"])
+			assert.Equal(t, "read", claims["scope"])
+			assert.Equal(t, float64(now.Unix()), claims["iat"])
+			assert.Equal(t, float64(now.Add(time.Minute).Unix()), claims["exp"])
+			assert.Len(t, claims["jti"], 32)
+			assert.True(t, tt.verify(input, signature))
+		})
+	}
+}
+
+func TestJWTAuthenticator_Credential(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+
+	a, err := NewJWTAuthenticator(JWTAlgorithmHS256, []byte("secret"))
+	assert.Nil(t, err)
+	a = a.WithTTL(time.Minute).WithRefreshBefore(10 * time.Second)
+	a.now = func() time.Time { return now }
+
+	u1, _ := url.Parse("https://api.local:443/products")
+	u2, _ := url.Parse("https://other.local:8443/products")
+
+	c1, err := a.Credential(context.Background(), u1)
+	assert.Nil(t, err)
+	assert.Equal(t, bearerAuthScheme, c1.Scheme)
+
+	_, claims, _, _ := decodeJWT(t, c1.Token)
+	assert.Equal(t, "api.local", claims["aud"])
+
+	c2, err := a.Credential(context.Background(), u2)
+	assert.Nil(t, err)
+
+	_, claims, _, _ = decodeJWT(t, c2.Token)
+	assert.Equal(t, "other.local:8443", claims["aud"])
+
+	// the cached token is reused until shortly before the expiry
+	now = now.Add(49 * time.Second)
+	cached, err := a.Credential(context.Background(), u1)
+	assert.Nil(t, err)
+	assert.Equal(t, c1, cached)
+
+	now = now.Add(2 * time.Second)
+	refreshed, err := a.Credential(context.Background(), u1)
+	assert.Nil(t, err)
+	assert.NotEqual(t, c1, refreshed)
+
+	// static audience
+	a = a.WithAudience(StaticJWTAudience("my-api"))
+	c3, err := a.Credential(context.Background(), u2)
+	assert.Nil(t, err)
+
+	_, claims, _, _ = decodeJWT(t, c3.Token)
+	assert.Equal(t, "my-api", claims["aud"])
+}
+
+func TestJWTAuthenticator_Do(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var auth string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get(authorizationHeaderKey)
+	})
+
+	a, err := NewJWTAuthenticator(JWTAlgorithmHS256, []byte("secret"))
+	assert.Nil(t, err)
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithCredentialProvider(a)
+
+	_, err = c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(auth, "Bearer ey"))
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	result, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	assert.Nil(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, result)
+
+	result, err = ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+	assert.Nil(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, result)
+
+	result, err = ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}))
+	assert.Nil(t, err)
+	assert.IsType(t, ed25519.PrivateKey{}, result)
+
+	_, err = ParsePrivateKeyPEM([]byte("not a key"))
+	assert.NotNil(t, err)
+
+	_, err = ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{}}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned when the response body exceeds the maximum
+// size set by WithMaxResponseSize
+var ErrBodyTooLarge = errors.New("response body exceeds the maximum size")
+
+// WithMaxResponseSize sets the maximum size of the response bodies, and
+// returns the BaseClient. A larger announced Content-Length fails the call
+// and reading beyond the limit fails with ErrBodyTooLarge, zero disables the limit
+func (c *BaseClient) WithMaxResponseSize(maxSize int64) *BaseClient {
+	if maxSize >= 0 {
+		c.maxResponseSize = maxSize
+	}
+	return c
+}
+
+// WithDrainLimit sets the number of bytes read from the discarded response
+// bodies so their connection can be reused, and returns the BaseClient
+func (c *BaseClient) WithDrainLimit(limit int64) *BaseClient {
+	if limit >= 0 {
+		c.drainLimit = limit
+	}
+	return c
+}
+
+// WithMaxResponseSize sets the maximum size of the response body, which wins
+// over the one of the client, and returns the Request
+func (r *Request) WithMaxResponseSize(maxSize int64) *Request {
+	r.maxResponseSize = maxSize
+	return r
+}
+
+// maxResponseSizeOf returns the maximum size of the response body of the request
+func (c *BaseClient) maxResponseSizeOf(req *Request) int64 {
+	if req.maxResponseSize > 0 {
+		return req.maxResponseSize
+	}
+	return c.maxResponseSize
+}
+
+// bodyless checks if the response has no body whatever its Content-Length,
+// the one of a HEAD request or of a 1xx, 204 or 304 status
+func bodyless(resp *http.Response) bool {
+	return resp.StatusCode < http.StatusOK || resp.StatusCode == http.StatusNoContent ||
+		resp.StatusCode == http.StatusNotModified || resp.Request != nil && resp.Request.Method == http.MethodHead
+}
+
+// limitedBody fails the reads beyond the limit
+type limitedBody struct {
+	body  io.ReadCloser
+	limit int64
+	read  int64
+	err   error
+}
+
+// Read reads the body, one byte past the limit is enough to detect the excess
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if remain := l.limit - l.read + 1; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+
+	n, err := l.body.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		l.err = ErrBodyTooLarge
+		return n - int(l.read-l.limit), l.err
+	}
+	return n, err
+}
+
+// Close closes the body
+func (l *limitedBody) Close() error {
+	return l.body.Close()
+}
@@ -0,0 +1,181 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseClient_WithMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/announced", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		for i := 0; i < 10; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("c", 10)))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	c := NewClient(logrus.New()).WithRetryMax(2).WithMaxResponseSize(50)
+
+	// the announced length is rejected up front, without retries
+	resp, err := c.Get(context.Background(), u+"/announced")
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// the length unknown up front is enforced while reading
+	_, err = c.Get(context.Background(), u+"/chunked")
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the limit of the request wins
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u+"/chunked", nil)
+	assert.Nil(t, err)
+	resp, err = c.Do(req.WithMaxResponseSize(100))
+	assert.Nil(t, err)
+	body, err := resp.GetBody()
+	assert.Nil(t, err)
+	assert.Len(t, body, 100)
+}
+
+func TestBaseClient_WithMaxResponseSize_bodyless(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentLengthHeaderKey, "100000")
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(strings.Repeat("a", 100000)))
+		}
+	})
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithMaxResponseSize(1000)
+
+	// the announced length of a HEAD response has no body
+	resp, err := c.Head(context.Background(), u+"/large")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+	assert.Equal(t, int64(100000), resp.RawResponse.ContentLength)
+
+	// neither has the one of a 304
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u+"/large", nil)
+	assert.Nil(t, err)
+	req.Header.Set("If-None-Match", `"v1"`)
+	resp, err = c.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.GetStatusCode())
+
+	_, err = c.Get(context.Background(), u+"/large")
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+}
+
+func TestBaseClient_WithMaxResponseSize_streaming(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("s", 10)))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	c := NewClient(logrus.New()).WithMaxResponseSize(50).WithStreaming(0)
+
+	resp, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Stream)
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+	assert.Len(t, b, 50)
+	assert.Equal(t, int64(50), resp.Stream.BytesRead())
+	assert.Nil(t, resp.Stream.Close())
+}
+
+// closeCounter is a response body counting its closes
+type closeCounter struct {
+	io.Reader
+	closes *int32
+}
+
+func (b closeCounter) Close() error {
+	atomic.AddInt32(b.closes, 1)
+	return nil
+}
+
+// roundTripperFunc adapts a function to an http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestBaseClient_WithMaxResponseSize_retried(t *testing.T) {
+	t.Parallel()
+
+	var calls, closes int32
+	c := NewClient(logrus.New()).WithRetryMax(1).WithMaxResponseSize(50).
+		WithBackoffStrategy(func(int) time.Duration { return 0 }).
+		WithRetryPolicy(func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+			return errors.Is(err, ErrBodyTooLarge), err
+		})
+	c.hc = &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			ContentLength: 100,
+			Body:          closeCounter{Reader: strings.NewReader(strings.Repeat("a", 100)), closes: &closes},
+			Request:       r,
+		}, nil
+	})}
+
+	// the rejected response is closed before the retry, the final one after it
+	_, err := c.Get(context.Background(), "http://example.com/")
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&closes))
+}
+
+func TestBaseClient_WithDrainLimit(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient(logrus.New())
+	assert.Equal(t, defaultDrainLimit, c.drainLimit)
+
+	c.WithDrainLimit(1 << 20)
+	assert.Equal(t, int64(1<<20), c.drainLimit)
+
+	// negative limits are ignored
+	c.WithDrainLimit(-1)
+	assert.Equal(t, int64(1<<20), c.drainLimit)
+}
@@ -0,0 +1,318 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes reported to the MetricsCollector
+const (
+	OutcomeSuccess  string = "success"
+	OutcomeError    string = "error"
+	OutcomeCanceled string = "canceled"
+)
+
+// default latency histogram buckets, in seconds
+var defaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// AttemptMetrics holds the data of a single attempt
+type AttemptMetrics struct {
+	Method      string
+	Host        string
+	StatusClass string
+	Outcome     string
+	Attempt     int
+	Duration    time.Duration
+}
+
+// RequestMetrics holds the data of a logical call, including all its attempts
+type RequestMetrics struct {
+	Method      string
+	Host        string
+	StatusClass string
+	Outcome     string
+	Attempts    int
+	Duration    time.Duration
+
+	// GaveUp is true when the retries were exhausted
+	GaveUp bool
+}
+
+// MetricsCollector is fed by BaseClient.Do with the data of every call
+type MetricsCollector interface {
+	// RequestStarted is called when a logical call starts
+	RequestStarted(method, host string)
+
+	// AttemptFinished is called after every attempt
+	AttemptFinished(m AttemptMetrics)
+
+	// BackoffStarted is called when a retry is scheduled after the wait
+	BackoffStarted(method, host string, wait time.Duration)
+
+	// RequestFinished is called when a logical call ends
+	RequestFinished(m RequestMetrics)
+}
+
+// statusClass returns the class ("2xx", "4xx", ...) of the status code or
+// "none" when there is no response
+func statusClass(resp *http.Response) string {
+	if resp == nil || resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return "none"
+	}
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
+
+// WithMetricsCollector sets the metrics collector and returns the BaseClient
+func (c *BaseClient) WithMetricsCollector(m MetricsCollector) *BaseClient {
+	c.metrics = m
+	return c
+}
+
+// histogram is a cumulative Prometheus style histogram
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// observe adds the value to the histogram
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// InMemoryMetrics is a MetricsCollector which keeps the metrics in memory and
+// exposes them in the Prometheus text exposition format
+type InMemoryMetrics struct {
+	namespace string
+	buckets   []float64
+
+	mu          sync.Mutex
+	requests    map[string]uint64
+	attempts    map[string]uint64
+	retries     map[string]uint64
+	backoffWait map[string]float64
+	inFlight    map[string]int64
+	giveUps     map[string]uint64
+	latency     map[string]*histogram
+}
+
+// NewInMemoryMetrics creates a new InMemoryMetrics. The namespace prefixes the metric names
+func NewInMemoryMetrics(namespace string) *InMemoryMetrics {
+	return &InMemoryMetrics{
+		namespace:   namespace,
+		buckets:     defaultLatencyBuckets,
+		requests:    make(map[string]uint64),
+		attempts:    make(map[string]uint64),
+		retries:     make(map[string]uint64),
+		backoffWait: make(map[string]float64),
+		inFlight:    make(map[string]int64),
+		giveUps:     make(map[string]uint64),
+		latency:     make(map[string]*histogram),
+	}
+}
+
+// WithBuckets sets the latency histogram buckets (in seconds) and returns the
+// InMemoryMetrics. The latencies observed with the previous buckets are discarded
+func (m *InMemoryMetrics) WithBuckets(buckets []float64) *InMemoryMetrics {
+	if len(buckets) == 0 {
+		return m
+	}
+
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buckets = b
+	m.latency = make(map[string]*histogram)
+	return m
+}
+
+// RequestStarted increments the in-flight gauge
+func (m *InMemoryMetrics) RequestStarted(method, host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[labels("method", method, "host", host)]++
+}
+
+// AttemptFinished counts the attempt
+func (m *InMemoryMetrics) AttemptFinished(a AttemptMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts[labels("method", a.Method, "host", a.Host, "status_class", a.StatusClass, "outcome", a.Outcome)]++
+}
+
+// BackoffStarted counts the retry and its wait
+func (m *InMemoryMetrics) BackoffStarted(method, host string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := labels("method", method, "host", host)
+	m.retries[key]++
+	m.backoffWait[key] += wait.Seconds()
+}
+
+// RequestFinished counts the call, observes its latency and decrements the in-flight gauge
+func (m *InMemoryMetrics) RequestFinished(r RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := labels("method", r.Method, "host", r.Host)
+
+	m.inFlight[key]--
+	m.requests[labels("method", r.Method, "host", r.Host, "status_class", r.StatusClass, "outcome", r.Outcome)]++
+
+	if r.GaveUp {
+		m.giveUps[key]++
+	}
+
+	h, ok := m.latency[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latency[key] = h
+	}
+	h.observe(m.buckets, r.Duration.Seconds())
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *InMemoryMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	writeCounter(bw, m.name("requests_total"), "Total number of logical requests.", m.requests)
+	writeCounter(bw, m.name("attempts_total"), "Total number of attempts, including retries.", m.attempts)
+	writeCounter(bw, m.name("retries_total"), "Total number of retries.", m.retries)
+	writeFloatCounter(bw, m.name("backoff_wait_seconds_total"), "Total time spent waiting between retries.", m.backoffWait)
+	writeGauge(bw, m.name("requests_in_flight"), "Number of logical requests in flight.", m.inFlight)
+	writeCounter(bw, m.name("give_ups_total"), "Total number of requests which exhausted their retries.", m.giveUps)
+	writeHistogram(bw, m.name("request_duration_seconds"), "Latency of the logical requests, including retries.", m.buckets, m.latency)
+
+	return bw.Flush()
+}
+
+// Handler returns a http.Handler serving the metrics
+func (m *InMemoryMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, prometheusContentType)
+		_ = m.WritePrometheus(w)
+	})
+}
+
+// name returns the full metric name
+func (m *InMemoryMetrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+// labels renders the label pairs in the exposition format
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(pairs[i+1]))
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+// escapeLabelValue escapes the backslash, the double-quote and the line feed
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// sortedKeys returns the keys of the map in order, so the output is stable
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]uint64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]float64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]int64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeHeader writes the HELP and TYPE lines
+func writeHeader(w io.Writer, name, help, typ string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeCounter writes a counter family
+func writeCounter(w io.Writer, name, help string, values map[string]uint64) {
+	writeHeader(w, name, help, "counter")
+	for _, k := range sortedKeys(values) {
+		_, _ = fmt.Fprintf(w, "%s{%s} %d\n", name, k, values[k])
+	}
+}
+
+// writeFloatCounter writes a counter family with float values
+func writeFloatCounter(w io.Writer, name, help string, values map[string]float64) {
+	writeHeader(w, name, help, "counter")
+	for _, k := range sortedKeys(values) {
+		_, _ = fmt.Fprintf(w, "%s{%s} %s\n", name, k, formatFloat(values[k]))
+	}
+}
+
+// writeGauge writes a gauge family
+func writeGauge(w io.Writer, name, help string, values map[string]int64) {
+	writeHeader(w, name, help, "gauge")
+	for _, k := range sortedKeys(values) {
+		_, _ = fmt.Fprintf(w, "%s{%s} %d\n", name, k, values[k])
+	}
+}
+
+// writeHistogram writes a histogram family
+func writeHistogram(w io.Writer, name, help string, buckets []float64, values map[string]*histogram) {
+	writeHeader(w, name, help, "histogram")
+	for _, k := range sortedKeys(values) {
+		h := values[k]
+		for i, b := range buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, k, formatFloat(b), h.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k, h.count)
+		_, _ = fmt.Fprintf(w, "%s_sum{%s} %s\n", name, k, formatFloat(h.sum))
+		_, _ = fmt.Fprintf(w, "%s_count{%s} %d\n", name, k, h.count)
+	}
+}
+
+// formatFloat formats the float as expected by Prometheus
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
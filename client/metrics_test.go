@@ -0,0 +1,202 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_statusClass(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "none", statusClass(nil))
+	assert.Equal(t, "none", statusClass(&http.Response{}))
+	assert.Equal(t, "2xx", statusClass(&http.Response{StatusCode: 204}))
+	assert.Equal(t, "5xx", statusClass(&http.Response{StatusCode: 503}))
+}
+
+func Test_labels(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `a="1",b="x\"y\\z\n"`, labels("a", "1", "b", "x\"y\\z\n"))
+}
+
+func TestInMemoryMetrics_WritePrometheus(t *testing.T) {
+	t.Parallel()
+
+	m := NewInMemoryMetrics("http_client").WithBuckets([]float64{1, 0.1})
+
+	m.RequestStarted("GET", "api.local")
+	m.RequestStarted("GET", "api.local")
+	m.AttemptFinished(AttemptMetrics{Method: "GET", Host: "api.local", StatusClass: "5xx", Outcome: OutcomeSuccess, Attempt: 1})
+	m.BackoffStarted("GET", "api.local", 1500*time.Millisecond)
+	m.AttemptFinished(AttemptMetrics{Method: "GET", Host: "api.local", StatusClass: "2xx", Outcome: OutcomeSuccess, Attempt: 2})
+	m.RequestFinished(RequestMetrics{Method: "GET", Host: "api.local", StatusClass: "2xx", Outcome: OutcomeSuccess, Attempts: 2, Duration: 500 * time.Millisecond})
+
+	var buf bytes.Buffer
+	err := m.WritePrometheus(&buf)
+	assert.Nil(t, err)
+
+	assert.Equal(t, `# HELP http_client_requests_total Total number of logical requests.
+# TYPE http_client_requests_total counter
+http_client_requests_total{method="GET",host="api.local",status_class="2xx",outcome="success"} 1
+# HELP http_client_attempts_total Total number of attempts, including retries.
+# TYPE http_client_attempts_total counter
+http_client_attempts_total{method="GET",host="api.local",status_class="2xx",outcome="success"} 1
+http_client_attempts_total{method="GET",host="api.local",status_class="5xx",outcome="success"} 1
+# HELP http_client_retries_total Total number of retries.
+# TYPE http_client_retries_total counter
+http_client_retries_total{method="GET",host="api.local"} 1
+# HELP http_client_backoff_wait_seconds_total Total time spent waiting between retries.
+# TYPE http_client_backoff_wait_seconds_total counter
+http_client_backoff_wait_seconds_total{method="GET",host="api.local"} 1.5
+# HELP http_client_requests_in_flight Number of logical requests in flight.
+# TYPE http_client_requests_in_flight gauge
+http_client_requests_in_flight{method="GET",host="api.local"} 1
+# HELP http_client_give_ups_total Total number of requests which exhausted their retries.
+# TYPE http_client_give_ups_total counter
+# HELP http_client_request_duration_seconds Latency of the logical requests, including retries.
+# TYPE http_client_request_duration_seconds histogram
+http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="0.1"} 0
+http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="1"} 1
+http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="+Inf"} 1
+http_client_request_duration_seconds_sum{method="GET",host="api.local"} 0.5
+http_client_request_duration_seconds_count{method="GET",host="api.local"} 1
+`, buf.String())
+}
+
+func TestInMemoryMetrics_WithBuckets(t *testing.T) {
+	t.Parallel()
+
+	m := NewInMemoryMetrics("http_client")
+	m.RequestStarted("GET", "api.local")
+	m.RequestFinished(RequestMetrics{Method: "GET", Host: "api.local", StatusClass: "2xx", Outcome: OutcomeSuccess, Attempts: 1, Duration: 500 * time.Millisecond})
+
+	// the histograms of the previous buckets are discarded
+	m.WithBuckets([]float64{2, 1, 0.1})
+	m.RequestStarted("GET", "api.local")
+	m.RequestFinished(RequestMetrics{Method: "GET", Host: "api.local", StatusClass: "2xx", Outcome: OutcomeSuccess, Attempts: 1, Duration: 1500 * time.Millisecond})
+
+	var buf bytes.Buffer
+	assert.Nil(t, m.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), `http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="1"} 0
+http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="2"} 1
+http_client_request_duration_seconds_bucket{method="GET",host="api.local",le="+Inf"} 1
+http_client_request_duration_seconds_sum{method="GET",host="api.local"} 1.5
+http_client_request_duration_seconds_count{method="GET",host="api.local"} 1
+`)
+	assert.Contains(t, buf.String(), `http_client_requests_total{method="GET",host="api.local",status_class="2xx",outcome="success"} 2`)
+}
+
+func TestInMemoryMetrics_Handler(t *testing.T) {
+	t.Parallel()
+
+	m := NewInMemoryMetrics("")
+	m.RequestStarted("GET", "api.local")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, prometheusContentType, rec.Header().Get(contentTypeHeaderKey))
+	assert.Contains(t, rec.Body.String(), `requests_in_flight{method="GET",host="api.local"} 1`)
+}
+
+// recordingMetrics is a MetricsCollector keeping the reported data
+type recordingMetrics struct {
+	started  int
+	attempts []AttemptMetrics
+	waits    []time.Duration
+	finished []RequestMetrics
+}
+
+func (r *recordingMetrics) RequestStarted(_, _ string) { r.started++ }
+
+func (r *recordingMetrics) AttemptFinished(m AttemptMetrics) { r.attempts = append(r.attempts, m) }
+
+func (r *recordingMetrics) BackoffStarted(_, _ string, wait time.Duration) {
+	r.waits = append(r.waits, wait)
+}
+
+func (r *recordingMetrics) RequestFinished(m RequestMetrics) { r.finished = append(r.finished, m) }
+
+func TestBaseClient_WithMetricsCollector(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	host := strings.TrimPrefix(u, "http://")
+
+	m := &recordingMetrics{}
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithMetricsCollector(m)
+
+	_, err := c.Get(ctx, u)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, m.started)
+	assert.Len(t, m.attempts, 2)
+	assert.Equal(t, "5xx", m.attempts[0].StatusClass)
+	assert.Equal(t, "2xx", m.attempts[1].StatusClass)
+	assert.Equal(t, []time.Duration{time.Millisecond}, m.waits)
+	assert.Len(t, m.finished, 1)
+	assert.Equal(t, "GET", m.finished[0].Method)
+	assert.Equal(t, host, m.finished[0].Host)
+	assert.Equal(t, "2xx", m.finished[0].StatusClass)
+	assert.Equal(t, OutcomeSuccess, m.finished[0].Outcome)
+	assert.Equal(t, 2, m.finished[0].Attempts)
+	assert.False(t, m.finished[0].GaveUp)
+
+	// give up after the retries
+	c = c.WithRetryMax(0)
+	_, err = c.Get(ctx, u)
+	assert.NotNil(t, err)
+	assert.Equal(t, OutcomeError, m.finished[1].Outcome)
+	assert.Equal(t, "5xx", m.finished[1].StatusClass)
+	assert.True(t, m.finished[1].GaveUp)
+
+	// canceled
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Get(cctx, u)
+	assert.NotNil(t, err)
+	assert.Equal(t, OutcomeCanceled, m.finished[2].Outcome)
+	assert.Equal(t, "none", m.finished[2].StatusClass)
+	assert.Equal(t, OutcomeError, m.attempts[len(m.attempts)-1].Outcome)
+
+	// the in-memory collector renders the calls
+	im := NewInMemoryMetrics("app")
+	c = c.WithRetryMax(0).WithMetricsCollector(im)
+	_, _ = c.Get(ctx, u)
+
+	srv := httptest.NewServer(im.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	assert.Contains(t, string(b), `app_requests_total{method="GET",host="`+url.PathEscape(host)+`"`)
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultTokenExpiryDelta is how long before its expiry a token is considered expired
+const defaultTokenExpiryDelta time.Duration = 30 * time.Second
+
+// OAuthToken is an OAuth 2.0 token as returned by the token endpoint
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// Valid checks if the token has an access token which doesn't expire
+// within the provided delta
+func (t *OAuthToken) Valid(now time.Time, delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || now.Add(delta).Before(t.Expiry)
+}
+
+// setExpiry computes the absolute expiry from "expires_in"
+func (t *OAuthToken) setExpiry(now time.Time) {
+	if t.ExpiresIn > 0 {
+		t.Expiry = now.Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+}
+
+// OAuthTokenSource obtains and refreshes OAuth 2.0 tokens
+type OAuthTokenSource interface {
+	// Token obtains a new token (e.g. by running a login flow)
+	Token(ctx context.Context) (*OAuthToken, error)
+
+	// Refresh exchanges the refresh token of the provided token for a new token
+	Refresh(ctx context.Context, token *OAuthToken) (*OAuthToken, error)
+}
+
+// OAuthTokenCache stores a token between runs
+type OAuthTokenCache interface {
+	// Load returns the cached token or nil when there is none
+	Load() (*OAuthToken, error)
+
+	// Save stores the token
+	Save(token *OAuthToken) error
+}
+
+// FileTokenCache is an OAuthTokenCache which keeps the token in a JSON file
+type FileTokenCache struct {
+	path string
+}
+
+// NewFileTokenCache creates a new FileTokenCache
+func NewFileTokenCache(path string) *FileTokenCache {
+	return &FileTokenCache{path: path}
+}
+
+// Load reads the token from the file
+func (c *FileTokenCache) Load() (*OAuthToken, error) {
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token OAuthToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Save writes the token to the file, readable only by the current user
+func (c *FileTokenCache) Save(token *OAuthToken) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, b, 0600)
+}
+
+// RefreshableBearerAuthenticator is a CredentialProvider which uses the access
+// token of an OAuthTokenSource as bearer token. An expired token is refreshed
+// with its refresh token, or a new one is obtained from the source
+type RefreshableBearerAuthenticator struct {
+	source OAuthTokenSource
+	cache  OAuthTokenCache
+	delta  time.Duration
+
+	now func() time.Time
+
+	mu    sync.Mutex
+	token *OAuthToken
+}
+
+// NewRefreshableBearerAuthenticator creates a new RefreshableBearerAuthenticator
+func NewRefreshableBearerAuthenticator(source OAuthTokenSource) *RefreshableBearerAuthenticator {
+	return &RefreshableBearerAuthenticator{
+		source: source,
+		delta:  defaultTokenExpiryDelta,
+		now:    time.Now,
+	}
+}
+
+// WithTokenCache sets the token cache and returns the RefreshableBearerAuthenticator
+func (a *RefreshableBearerAuthenticator) WithTokenCache(cache OAuthTokenCache) *RefreshableBearerAuthenticator {
+	a.cache = cache
+	return a
+}
+
+// WithExpiryDelta sets how long before its expiry a token is refreshed
+// and returns the RefreshableBearerAuthenticator
+func (a *RefreshableBearerAuthenticator) WithExpiryDelta(delta time.Duration) *RefreshableBearerAuthenticator {
+	if delta >= 0 {
+		a.delta = delta
+	}
+	return a
+}
+
+// Credential returns the bearer credential of a valid token
+func (a *RefreshableBearerAuthenticator) Credential(ctx context.Context, _ *url.URL) (*Credential, error) {
+	token, err := a.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{bearerAuthScheme, token.AccessToken}, nil
+}
+
+// Token returns a valid token, loading it from the cache, refreshing
+// it or obtaining a new one from the source when needed
+func (a *RefreshableBearerAuthenticator) Token(ctx context.Context) (*OAuthToken, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == nil && a.cache != nil {
+		token, err := a.cache.Load()
+		if err != nil {
+			return nil, fmt.Errorf("error loading the cached token: %w", err)
+		}
+		a.token = token
+	}
+
+	if a.token.Valid(a.now(), a.delta) {
+		return a.token, nil
+	}
+
+	return a.renew(ctx)
+}
+
+// Refresh discards the current access token and renews it, e.g. after
+// the server revoked it
+func (a *RefreshableBearerAuthenticator) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err := a.renew(ctx)
+	return err
+}
+
+// renew refreshes the current token or obtains a new one and caches it.
+// The caller must hold the lock
+func (a *RefreshableBearerAuthenticator) renew(ctx context.Context) (*OAuthToken, error) {
+	var token *OAuthToken
+	var err error
+
+	if a.token != nil && a.token.RefreshToken != "" {
+		token, err = a.source.Refresh(ctx, a.token)
+		if err == nil && token.RefreshToken == "" {
+			// the refresh token is kept when the server doesn't rotate it
+			token.RefreshToken = a.token.RefreshToken
+		}
+	}
+	if token == nil {
+		token, err = a.source.Token(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.token = token
+
+	if a.cache != nil {
+		if err := a.cache.Save(token); err != nil {
+			return nil, fmt.Errorf("error caching the token: %w", err)
+		}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,145 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokenSource is an OAuthTokenSource returning numbered tokens
+type fakeTokenSource struct {
+	tokens    int
+	refreshes int
+	err       error
+}
+
+func (s *fakeTokenSource) Token(_ context.Context) (*OAuthToken, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.tokens++
+	return &OAuthToken{
+		AccessToken:  fmt.Sprintf("token-%d", s.tokens),
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(time.Hour),
+	}, nil
+}
+
+func (s *fakeTokenSource) Refresh(_ context.Context, token *OAuthToken) (*OAuthToken, error) {
+	s.refreshes++
+	if token.RefreshToken != "refresh" {
+		return nil, fmt.Errorf("invalid_grant")
+	}
+	return &OAuthToken{
+		AccessToken: fmt.Sprintf("refreshed-%d", s.refreshes),
+		Expiry:      time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestOAuthToken_Valid(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	var token *OAuthToken
+	assert.False(t, token.Valid(now, 0))
+	assert.False(t, (&OAuthToken{}).Valid(now, 0))
+	assert.True(t, (&OAuthToken{AccessToken: "at"}).Valid(now, 0))
+	assert.True(t, (&OAuthToken{AccessToken: "at", Expiry: now.Add(time.Minute)}).Valid(now, 0))
+	assert.False(t, (&OAuthToken{AccessToken: "at", Expiry: now.Add(time.Minute)}).Valid(now, time.Minute))
+}
+
+func TestFileTokenCache(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	c := NewFileTokenCache(path)
+
+	token, err := c.Load()
+	assert.Nil(t, err)
+	assert.Nil(t, token)
+
+	expiry := time.Now().Add(time.Hour).Round(0).UTC()
+	err = c.Save(&OAuthToken{AccessToken: "at", RefreshToken: "rt", Expiry: expiry})
+	assert.Nil(t, err)
+
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	token, err = c.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, &OAuthToken{AccessToken: "at", RefreshToken: "rt", Expiry: expiry}, token)
+
+	err = ioutil.WriteFile(path, []byte("{"), 0600)
+	assert.Nil(t, err)
+	_, err = c.Load()
+	assert.NotNil(t, err)
+}
+
+func TestRefreshableBearerAuthenticator_Credential(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	source := &fakeTokenSource{}
+	a := NewRefreshableBearerAuthenticator(source).WithExpiryDelta(time.Minute)
+
+	result, err := a.Credential(ctx, &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token-1"}, result)
+
+	// the valid token is reused
+	result, err = a.Credential(ctx, &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "token-1"}, result)
+
+	// the token is refreshed within the expiry delta
+	a.now = func() time.Time { return time.Now().Add(time.Hour - 30*time.Second) }
+	result, err = a.Credential(ctx, &url.URL{})
+	assert.Nil(t, err)
+	assert.Equal(t, &Credential{bearerAuthScheme, "refreshed-1"}, result)
+	assert.Equal(t, 1, source.tokens)
+}
+
+func TestRefreshableBearerAuthenticator_Refresh(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	source := &fakeTokenSource{}
+	a := NewRefreshableBearerAuthenticator(source)
+
+	// without a token a new one is obtained
+	err := a.Refresh(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, source.tokens)
+
+	err = a.Refresh(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, source.refreshes)
+
+	token, err := a.Token(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "refreshed-1", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+
+	// a failed refresh falls back to a new token
+	a.token.RefreshToken = "revoked"
+	err = a.Refresh(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, source.tokens)
+
+	source.err = fmt.Errorf("login failed")
+	a.token = nil
+	err = a.Refresh(ctx)
+	assert.EqualError(t, err, "login failed")
+}
@@ -0,0 +1,351 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Outlier detection defaults
+const (
+	defaultEjectionFailures int           = 5
+	defaultEjectionCooldown time.Duration = 30 * time.Second
+)
+
+// ErrNoEndpoints is returned when a request targets an empty endpoint pool
+var ErrNoEndpoints = errors.New("the endpoint pool is empty")
+
+// Endpoint is a base URL of an EndpointPool and its load
+type Endpoint struct {
+	URL *url.URL
+
+	// Priority groups the endpoints, the lowest group with healthy endpoints
+	// gets the requests. Weight balances the requests within the group, the
+	// weights are ignored when all zero
+	Priority int
+	Weight   int
+
+	outstanding int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// NewEndpoint creates an endpoint of the absolute base URL, e.g. for a Discoverer
+func NewEndpoint(baseURL string, priority, weight int) (*Endpoint, error) {
+	u, err := parseBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Endpoint{URL: u, Priority: priority, Weight: weight}, nil
+}
+
+// Outstanding returns the number of requests in flight to the endpoint
+func (e *Endpoint) Outstanding() int64 {
+	return atomic.LoadInt64(&e.outstanding)
+}
+
+// Ejected checks if the endpoint is ejected by the outlier detection
+func (e *Endpoint) Ejected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return time.Now().Before(e.ejectedUntil)
+}
+
+// BalancingStrategy picks the endpoint of a request among the candidates,
+// never empty. The candidates share the same priority
+type BalancingStrategy func(candidates []*Endpoint) *Endpoint
+
+// weights returns the weights of the candidates, all equal when all zero
+func weights(candidates []*Endpoint) ([]int64, int64) {
+	w := make([]int64, len(candidates))
+	var total int64
+	for i, e := range candidates {
+		if e.Weight > 0 {
+			w[i] = int64(e.Weight)
+			total += w[i]
+		}
+	}
+	if total == 0 {
+		for i := range w {
+			w[i] = 1
+		}
+		total = int64(len(w))
+	}
+	return w, total
+}
+
+// weighted returns the candidate at the position of the cumulated weights
+func weighted(candidates []*Endpoint, w []int64, n int64) *Endpoint {
+	for i, weight := range w {
+		if n < weight {
+			return candidates[i]
+		}
+		n -= weight
+	}
+	return candidates[len(candidates)-1]
+}
+
+// lessLoaded checks if the endpoint a has fewer requests in flight per weight than b
+func lessLoaded(a, b *Endpoint, wa, wb int64) bool {
+	return (a.Outstanding()+1)*wb < (b.Outstanding()+1)*wa
+}
+
+// RoundRobinStrategy returns a strategy picking the candidates in turn, as
+// many times in a row as their weight
+func RoundRobinStrategy() BalancingStrategy {
+	var next uint64
+	return func(candidates []*Endpoint) *Endpoint {
+		w, total := weights(candidates)
+		n := atomic.AddUint64(&next, 1) - 1
+		return weighted(candidates, w, int64(n%uint64(total)))
+	}
+}
+
+// RandomStrategy returns a strategy picking a random candidate, in
+// proportion to its weight
+func RandomStrategy() BalancingStrategy {
+	return func(candidates []*Endpoint) *Endpoint {
+		w, total := weights(candidates)
+		return weighted(candidates, w, rand.Int63n(total))
+	}
+}
+
+// LeastOutstandingStrategy returns a strategy picking the candidate with the
+// fewest requests in flight per weight, the ties are broken randomly
+func LeastOutstandingStrategy() BalancingStrategy {
+	return func(candidates []*Endpoint) *Endpoint {
+		w, _ := weights(candidates)
+		offset := rand.Intn(len(candidates))
+		best := offset
+		for i := 1; i < len(candidates); i++ {
+			j := (offset + i) % len(candidates)
+			if lessLoaded(candidates[j], candidates[best], w[j], w[best]) {
+				best = j
+			}
+		}
+		return candidates[best]
+	}
+}
+
+// PowerOfTwoChoicesStrategy returns a strategy picking the candidate with the
+// fewest requests in flight per weight among two random ones
+func PowerOfTwoChoicesStrategy() BalancingStrategy {
+	return func(candidates []*Endpoint) *Endpoint {
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+		w, _ := weights(candidates)
+		i := rand.Intn(len(candidates))
+		j := rand.Intn(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+		if lessLoaded(candidates[j], candidates[i], w[j], w[i]) {
+			return candidates[j]
+		}
+		return candidates[i]
+	}
+}
+
+// EndpointPool balances the requests with a relative URL between base URLs.
+// The endpoints failing repeatedly are ejected for a cooldown period
+type EndpointPool struct {
+	strategy BalancingStrategy
+
+	ejectionFailures int
+	ejectionCooldown time.Duration
+
+	mu        sync.RWMutex
+	endpoints []*Endpoint
+}
+
+// NewEndpointPool creates a pool of the base URLs balanced by the strategy,
+// round-robin when nil
+func NewEndpointPool(strategy BalancingStrategy, baseURLs ...string) (*EndpointPool, error) {
+	if strategy == nil {
+		strategy = RoundRobinStrategy()
+	}
+
+	p := &EndpointPool{
+		strategy:         strategy,
+		ejectionFailures: defaultEjectionFailures,
+		ejectionCooldown: defaultEjectionCooldown,
+	}
+	for _, raw := range baseURLs {
+		u, err := parseBaseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.endpoints = append(p.endpoints, &Endpoint{URL: u})
+	}
+
+	return p, nil
+}
+
+// parseBaseURL parses an absolute base URL
+func parseBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", raw)
+	}
+	return u, nil
+}
+
+// WithOutlierDetection ejects the endpoints after the consecutive failures
+// for the cooldown, and returns the EndpointPool. A transport error or a 5xx
+// status is a failure, zero failures disables the ejection
+func (p *EndpointPool) WithOutlierDetection(failures int, cooldown time.Duration) *EndpointPool {
+	p.ejectionFailures = failures
+	p.ejectionCooldown = cooldown
+	return p
+}
+
+// Endpoints returns the endpoints of the pool
+func (p *EndpointPool) Endpoints() []*Endpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]*Endpoint(nil), p.endpoints...)
+}
+
+// pick returns the endpoint of the next attempt, avoiding the one which
+// failed the previous attempt. All the endpoints are candidates when every
+// one is ejected
+func (p *EndpointPool) pick(failed *Endpoint) (*Endpoint, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	candidates := make([]*Endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if !e.Ejected() {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, p.endpoints...)
+	}
+
+	if failed != nil && len(candidates) > 1 {
+		for i, e := range candidates {
+			if e == failed {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+
+	// keep the preferred priority
+	priority := candidates[0].Priority
+	for _, e := range candidates {
+		if e.Priority < priority {
+			priority = e.Priority
+		}
+	}
+	preferred := candidates[:0]
+	for _, e := range candidates {
+		if e.Priority == priority {
+			preferred = append(preferred, e)
+		}
+	}
+
+	return p.strategy(preferred), nil
+}
+
+// SetEndpoints replaces the endpoints of the pool. The endpoints with the URL
+// of a current one keep its load and health, the requests in flight to the
+// removed ones complete
+func (p *EndpointPool) SetEndpoints(endpoints []*Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := make(map[string]*Endpoint, len(p.endpoints))
+	for _, e := range p.endpoints {
+		current[e.URL.String()] = e
+	}
+
+	updated := make([]*Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		// the picks read the priority and the weight under the pool lock
+		if c, ok := current[e.URL.String()]; ok {
+			c.Priority, c.Weight = e.Priority, e.Weight
+			e = c
+		}
+		updated = append(updated, e)
+	}
+	p.endpoints = updated
+}
+
+// acquire counts a request in flight to the endpoint
+func (p *EndpointPool) acquire(e *Endpoint) {
+	atomic.AddInt64(&e.outstanding, 1)
+}
+
+// release records the outcome of a request to the endpoint, ejecting it
+// after the consecutive failures
+func (p *EndpointPool) release(e *Endpoint, success bool) {
+	atomic.AddInt64(&e.outstanding, -1)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if success {
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if p.ejectionFailures > 0 && e.failures >= p.ejectionFailures {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(p.ejectionCooldown)
+	}
+}
+
+// WithEndpointPool sends the requests with a relative URL to the endpoints of
+// the pool, and returns the BaseClient. The URL is resolved against the base
+// URL as in RFC 3986, the retries prefer another endpoint
+func (c *BaseClient) WithEndpointPool(p *EndpointPool) *BaseClient {
+	c.endpointPool = p
+	return c
+}
+
+// selectEndpoint resolves the relative URL of the request against an endpoint
+// of the pool, other than the failed one when possible
+func (c *BaseClient) selectEndpoint(req *Request, failed *Endpoint) error {
+	if c.endpointPool == nil {
+		return nil
+	}
+	if req.relativeURL == nil {
+		if req.URL.IsAbs() || req.URL.Host != "" {
+			return nil
+		}
+		req.relativeURL = req.URL
+	}
+
+	e, err := c.endpointPool.pick(failed)
+	if err != nil {
+		return err
+	}
+
+	// shallow copy, the relative URL is kept for the next attempts
+	httpReq := *req.Request
+	httpReq.URL = e.URL.ResolveReference(req.relativeURL)
+	httpReq.Host = ""
+	req.Request = &httpReq
+	req.endpoint = e
+
+	return nil
+}
@@ -0,0 +1,145 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEndpoints returns endpoints with the numbers of requests in flight
+func newTestEndpoints(outstanding ...int64) []*Endpoint {
+	endpoints := make([]*Endpoint, len(outstanding))
+	for i, n := range outstanding {
+		endpoints[i] = &Endpoint{outstanding: n}
+	}
+	return endpoints
+}
+
+func TestBalancingStrategy(t *testing.T) {
+	t.Parallel()
+
+	endpoints := newTestEndpoints(3, 1, 2)
+
+	rr := RoundRobinStrategy()
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, endpoints[i%3], rr(endpoints))
+	}
+
+	random := RandomStrategy()
+	assert.Contains(t, endpoints, random(endpoints))
+
+	least := LeastOutstandingStrategy()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, endpoints[1], least(endpoints))
+	}
+
+	// the better of the two is always picked
+	p2c := PowerOfTwoChoicesStrategy()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, endpoints[1], p2c(endpoints[:2]))
+	}
+	assert.Equal(t, endpoints[2], p2c(endpoints[2:]))
+
+	// the weights
+	weighted := newTestEndpoints(0, 0)
+	weighted[0].Weight, weighted[1].Weight = 3, 1
+	rr = RoundRobinStrategy()
+	for _, i := range []int{0, 0, 0, 1, 0, 0, 0, 1} {
+		assert.Equal(t, weighted[i], rr(weighted))
+	}
+
+	// two requests in flight on the endpoint of weight 3 is a lower load
+	weighted[0].outstanding, weighted[1].outstanding = 2, 1
+	assert.Equal(t, weighted[0], least(weighted))
+	assert.Equal(t, weighted[0], p2c(weighted))
+}
+
+func TestNewEndpointPool(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewEndpointPool(nil, "https://a.local", "https://b.local/api/")
+	assert.Nil(t, err)
+	assert.Len(t, p.Endpoints(), 2)
+
+	_, err = NewEndpointPool(nil, "/relative")
+	assert.NotNil(t, err)
+
+	// every endpoint is a candidate when all are ejected
+	p.WithOutlierDetection(1, time.Minute)
+	for _, e := range p.Endpoints() {
+		p.acquire(e)
+		p.release(e, false)
+		assert.True(t, e.Ejected())
+	}
+	e, err := p.pick(nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, e)
+
+	empty, err := NewEndpointPool(nil)
+	assert.Nil(t, err)
+	_, err = empty.pick(nil)
+	assert.True(t, errors.Is(err, ErrNoEndpoints))
+}
+
+func TestBaseClient_WithEndpointPool(t *testing.T) {
+	t.Parallel()
+
+	badMux, badURL, badShutdown := setup()
+	defer badShutdown()
+	goodMux, goodURL, goodShutdown := setup()
+	defer goodShutdown()
+
+	var badCalls, goodCalls int32
+	badMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCalls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	goodMux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodCalls, 1)
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+
+	pool, err := NewEndpointPool(RoundRobinStrategy(), badURL+"/api/", goodURL+"/api/")
+	assert.Nil(t, err)
+	pool.WithOutlierDetection(2, time.Minute)
+
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(int) time.Duration { return 0 }).
+		WithEndpointPool(pool)
+
+	// the retries go to the other endpoint
+	for i := 0; i < 6; i++ {
+		req, err := c.NewRequest(context.Background(), http.MethodGet, "users/1", nil)
+		assert.Nil(t, err)
+
+		resp, err := c.Do(req)
+		assert.Nil(t, err)
+		body, _ := resp.GetStringBody()
+		assert.Equal(t, "/api/users/1", body)
+		assert.Equal(t, "users/1", req.relativeURL.String())
+	}
+
+	// the failing endpoint is ejected after two failures
+	assert.Equal(t, int32(2), atomic.LoadInt32(&badCalls))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&goodCalls))
+	assert.True(t, pool.Endpoints()[0].Ejected())
+	assert.False(t, pool.Endpoints()[1].Ejected())
+	for _, e := range pool.Endpoints() {
+		assert.Equal(t, int64(0), e.Outstanding())
+	}
+
+	// the absolute URLs don't use the pool
+	_, err = c.Get(context.Background(), badURL+"/other")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&badCalls))
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces the redacted values
+const redactedValue string = "REDACTED"
+
+// default sensitive header names
+var defaultRedactedHeaders = []string{
+	authorizationHeaderKey,
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	csrfTokenHeaderKey,
+}
+
+// default sensitive query, form and JSON field names
+var defaultRedactedParams = []string{
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"client_secret",
+	"password",
+	"assertion",
+}
+
+// Redactor masks the sensitive values of the headers, the query and form
+// parameters and the JSON body fields. A nil Redactor keeps every value
+type Redactor struct {
+	headers map[string]bool
+	params  map[string]bool
+}
+
+// NewRedactor creates a new Redactor masking the credentials, the cookies and
+// the usual OAuth token parameters
+func NewRedactor() *Redactor {
+	r := &Redactor{
+		headers: make(map[string]bool),
+		params:  make(map[string]bool),
+	}
+	return r.WithHeaders(defaultRedactedHeaders...).WithParams(defaultRedactedParams...)
+}
+
+// WithHeaders adds the header names to redact and returns the Redactor
+func (r *Redactor) WithHeaders(names ...string) *Redactor {
+	for _, n := range names {
+		r.headers[http.CanonicalHeaderKey(n)] = true
+	}
+	return r
+}
+
+// WithParams adds the query, form and JSON field names to redact and returns the Redactor
+func (r *Redactor) WithParams(names ...string) *Redactor {
+	for _, n := range names {
+		r.params[strings.ToLower(n)] = true
+	}
+	return r
+}
+
+// redactsHeader checks if the header value is masked
+func (r *Redactor) redactsHeader(name string) bool {
+	return r != nil && r.headers[http.CanonicalHeaderKey(name)]
+}
+
+// redactsParam checks if the parameter value is masked
+func (r *Redactor) redactsParam(name string) bool {
+	return r != nil && r.params[strings.ToLower(name)]
+}
+
+// Header returns a copy of the header with the sensitive values masked
+func (r *Redactor) Header(h http.Header) http.Header {
+	result := h.Clone()
+	for k, values := range result {
+		if r.redactsHeader(k) {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return result
+}
+
+// Values returns a copy of the values with the sensitive ones masked
+func (r *Redactor) Values(v url.Values) url.Values {
+	result := make(url.Values, len(v))
+	for k, values := range v {
+		values = append([]string(nil), values...)
+		if r.redactsParam(k) {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+		result[k] = values
+	}
+	return result
+}
+
+// URL returns the URL with the sensitive query parameters masked
+func (r *Redactor) URL(u *url.URL) string {
+	if r == nil || u.RawQuery == "" {
+		return u.String()
+	}
+	redacted := *u
+	redacted.RawQuery = r.Values(u.Query()).Encode()
+	return redacted.String()
+}
+
+// Body returns the form or JSON body with the sensitive fields masked.
+// Other bodies are returned as they are
+func (r *Redactor) Body(contentType string, body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == formContentType:
+		v, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		return []byte(r.Values(v).Encode())
+	case mediaType == jsonContentType || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return body
+		}
+		b, err := json.Marshal(r.jsonValue(v))
+		if err != nil {
+			return body
+		}
+		return b
+	}
+	return body
+}
+
+// jsonValue masks the sensitive fields of the decoded JSON value, recursively
+func (r *Redactor) jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if r.redactsParam(k) {
+				val[k] = redactedValue
+			} else {
+				val[k] = r.jsonValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.jsonValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_Header(t *testing.T) {
+	t.Parallel()
+
+	h := http.Header{}
+	h.Set(authorizationHeaderKey, "Bearer token")
+	h.Set("X-Api-Key", "key")
+	h.Set(acceptHeaderKey, jsonContentType)
+
+	r := NewRedactor().WithHeaders("x-api-key")
+	result := r.Header(h)
+	assert.Equal(t, redactedValue, result.Get(authorizationHeaderKey))
+	assert.Equal(t, redactedValue, result.Get("X-Api-Key"))
+	assert.Equal(t, jsonContentType, result.Get(acceptHeaderKey))
+
+	// the original header is kept
+	assert.Equal(t, "Bearer token", h.Get(authorizationHeaderKey))
+
+	// a nil redactor keeps every value
+	var nilRedactor *Redactor
+	assert.Equal(t, h, nilRedactor.Header(h))
+}
+
+func TestRedactor_URL(t *testing.T) {
+	t.Parallel()
+
+	u, _ := url.Parse("https://api.local/path?access_token=secret&page=2")
+
+	assert.Equal(t, "https://api.local/path?access_token=REDACTED&page=2", NewRedactor().URL(u))
+	assert.Equal(t, "https://api.local/path?access_token=secret&page=2", u.String())
+
+	var nilRedactor *Redactor
+	assert.Equal(t, u.String(), nilRedactor.URL(u))
+}
+
+func TestRedactor_Body(t *testing.T) {
+	t.Parallel()
+
+	r := NewRedactor().WithParams("Secret")
+
+	assert.Equal(t, "password=REDACTED&username=john",
+		string(r.Body(formContentType, []byte("username=john&password=pass"))))
+	assert.Equal(t, `{"items":[{"secret":"REDACTED"}],"name":"john","refresh_token":"REDACTED"}`,
+		string(r.Body(jsonContentType+"; charset=utf-8", []byte(`{"name":"john","refresh_token":"x","items":[{"secret":{"a":1}}]}`))))
+	assert.Equal(t, `{"access_token":"REDACTED"}`,
+		string(r.Body("application/problem+json", []byte(`{"access_token":"x"}`))))
+
+	// other or invalid bodies are kept
+	assert.Equal(t, "password=pass", string(r.Body("text/plain", []byte("password=pass"))))
+	assert.Equal(t, "{invalid", string(r.Body(jsonContentType, []byte("{invalid"))))
+}
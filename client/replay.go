@@ -0,0 +1,318 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// defaultReplayScheme is the scheme of the replayed dumps, which only hold the path
+const defaultReplayScheme string = "https"
+
+// headers of the captured requests which aren't replayed, the client sets them on its own
+var replaySkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Accept-Encoding":   true,
+	attemptHeaderKey:    true,
+}
+
+// ReplayResult is the outcome of a replayed audit record
+type ReplayResult struct {
+	// Line is the 1-based line of the record in the audit file
+	Line int
+
+	// Record is the recorded call
+	Record AuditRecord
+
+	// Response is the response of the replayed call
+	Response *Response
+
+	// Err is the error of the replay
+	Err error
+
+	// StatusMatch is true when the replayed status is the recorded one
+	StatusMatch bool
+
+	// BodyMatch is true when the replayed body is the recorded one, JSON
+	// bodies are compared semantically
+	BodyMatch bool
+}
+
+// Match checks if the replay succeeded and matched the recorded response
+func (r ReplayResult) Match() bool {
+	return r.Err == nil && r.StatusMatch && r.BodyMatch
+}
+
+// Replayer re-sends captured requests, from DataDump or audit files, through a BaseClient
+type Replayer struct {
+	client *BaseClient
+
+	baseURL     *url.URL
+	host        string
+	concurrency int
+	redactor    *Redactor
+}
+
+// NewReplayer creates a new Replayer sending the requests through the client
+func NewReplayer(c *BaseClient) *Replayer {
+	return &Replayer{client: c, concurrency: 1}
+}
+
+// WithBaseURL sets the URL the requests are sent to, the captured path is
+// appended to its path, and returns the Replayer
+func (r *Replayer) WithBaseURL(u *url.URL) *Replayer {
+	r.baseURL = u
+	return r
+}
+
+// WithHost sets the host the requests are sent to and returns the Replayer
+func (r *Replayer) WithHost(host string) *Replayer {
+	r.host = host
+	return r
+}
+
+// WithConcurrency sets the number of audit records replayed at the same time and returns the Replayer
+func (r *Replayer) WithConcurrency(n int) *Replayer {
+	if n > 0 {
+		r.concurrency = n
+	}
+	return r
+}
+
+// WithRedactor sets the redactor applied to the replayed bodies before the
+// comparison, it should match the one of the AuditSink, and returns the Replayer
+func (r *Replayer) WithRedactor(redactor *Redactor) *Replayer {
+	r.redactor = redactor
+	return r
+}
+
+// NewRequest parses the dumped HTTP/1.1 request and creates the request
+// through BaseClient.NewRequest, rewriting its URL. The captured request ID
+// is kept, so the replays can be correlated with the original calls
+func (r *Replayer) NewRequest(ctx context.Context, dump []byte) (*Request, error) {
+	req, body, err := readRequestDump(dump)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &url.URL{
+		Scheme:   defaultReplayScheme,
+		Host:     req.Host,
+		Path:     req.URL.Path,
+		RawPath:  req.URL.RawPath,
+		RawQuery: req.URL.RawQuery,
+	}
+
+	return r.newRequest(ctx, req.Method, u, req.Header, body)
+}
+
+// Replay re-sends the request of the dump
+func (r *Replayer) Replay(ctx context.Context, dump *DataDump) (*Response, error) {
+	req, err := r.NewRequest(ctx, dump.RequestDump)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.Do(req)
+}
+
+// newRequest creates the request with the rewritten URL. The redacted header
+// values are left out, the client applies its own auth
+func (r *Replayer) newRequest(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*Request, error) {
+	u = r.rewrite(u)
+
+	var rawBody interface{}
+	if len(body) > 0 {
+		rawBody = bytes.NewReader(body)
+	}
+
+	req, err := r.client.NewRequest(ctx, method, u.String(), rawBody)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, values := range header {
+		if replaySkippedHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		for _, v := range values {
+			if v != redactedValue {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// rewrite returns the URL pointing to the base URL or the host
+func (r *Replayer) rewrite(u *url.URL) *url.URL {
+	result := *u
+	if r.baseURL != nil {
+		result.Scheme = r.baseURL.Scheme
+		result.Host = r.baseURL.Host
+		result.Path = strings.TrimSuffix(r.baseURL.Path, "/") + u.Path
+		if u.RawPath != "" {
+			result.RawPath = strings.TrimSuffix(r.baseURL.EscapedPath(), "/") + u.RawPath
+		}
+	}
+	if r.host != "" {
+		result.Host = r.host
+	}
+	return &result
+}
+
+// ReplayAuditFile replays the records of the JSONL audit file, see ReplayAudit
+func (r *Replayer) ReplayAuditFile(ctx context.Context, path string) ([]ReplayResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return r.ReplayAudit(ctx, f)
+}
+
+// ReplayAudit replays the records of the JSONL audit log, with the configured
+// concurrency, and compares the new responses against the recorded ones.
+// The results are in the order of the records
+func (r *Replayer) ReplayAudit(ctx context.Context, rd io.Reader) ([]ReplayResult, error) {
+	var results []ReplayResult
+
+	br := bufio.NewReader(rd)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			result := ReplayResult{Line: line}
+			if jsonErr := json.Unmarshal(b, &result.Record); jsonErr != nil {
+				result.Err = fmt.Errorf("line %d: %w", line, jsonErr)
+			}
+			results = append(results, result)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return results, ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(result *ReplayResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r.replayRecord(ctx, result)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// replayRecord replays the record and compares the responses
+func (r *Replayer) replayRecord(ctx context.Context, result *ReplayResult) {
+	record := result.Record
+
+	u, err := url.Parse(record.URL)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	body, err := decodeAuditBody(record.RequestBody, record.RequestBodyEncoding)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	req, err := r.newRequest(ctx, record.Method, u, record.RequestHeaders, body)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	result.Response, result.Err = r.client.Do(req)
+	if result.Response == nil || result.Response.RawResponse == nil {
+		return
+	}
+
+	result.StatusMatch = result.Response.GetStatusCode() == record.Status
+
+	recorded, err := decodeAuditBody(record.ResponseBody, record.ResponseBodyEncoding)
+	if err != nil {
+		result.Err = err
+		return
+	}
+
+	contentType, replayed, err := responseBody(result.Response)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	replayed = r.redactor.Body(contentType, replayed)
+	result.BodyMatch = equalBodies(recorded, replayed)
+}
+
+// responseBody returns the content type and the body of the response. The
+// body of a failed call is drained, its dump keeps it. The responses served
+// from the cache or by a fallback have no attempt dump
+func responseBody(resp *Response) (string, []byte, error) {
+	var dump []byte
+	switch {
+	case resp.DataDump != nil && resp.DataDump.ResponseDump != nil:
+		dump = resp.DataDump.ResponseDump
+	case len(resp.AttemptDumps) > 0:
+		dump = resp.AttemptDumps[len(resp.AttemptDumps)-1].ResponseDump
+	}
+
+	if dump == nil {
+		body, err := resp.GetBody()
+		return resp.GetHeaders().Get(contentTypeHeaderKey), body, err
+	}
+
+	dumped, body, err := readResponseDump(dump, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return dumped.Header.Get(contentTypeHeaderKey), body, nil
+}
+
+// equalBodies compares the bodies, semantically when both are JSON
+func equalBodies(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,215 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayer_rewrite(t *testing.T) {
+	t.Parallel()
+
+	u, _ := url.Parse("https://partner.api/orders/a%2Fb?x=1")
+	base, _ := url.Parse("http://127.0.0.1:8080/sandbox/")
+
+	r := NewReplayer(NewClient(logrus.New()))
+	assert.Equal(t, u.String(), r.rewrite(u).String())
+	assert.Equal(t, "http://127.0.0.1:8080/sandbox/orders/a%2Fb?x=1", r.WithBaseURL(base).rewrite(u).String())
+	assert.Equal(t, "http://localhost/sandbox/orders/a%2Fb?x=1", r.WithHost("localhost").rewrite(u).String())
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var received *http.Request
+	var body string
+	mux.HandleFunc("/sandbox/orders", func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	// the dump of a failed call
+	original, _ := http.NewRequest(http.MethodPost, "https://partner.api/orders?id=1", strings.NewReader(`{"amount":10}`))
+	original.Header.Set(contentTypeHeaderKey, jsonContentType)
+	original.Header.Set(authorizationHeaderKey, redactedValue)
+	original.Header.Set(requestIDHeaderKey, "original-id")
+	original.Header.Set(attemptHeaderKey, "3")
+	dump, err := httputil.DumpRequestOut(original, true)
+	assert.Nil(t, err)
+
+	base, _ := url.Parse(u + "/sandbox")
+	r := NewReplayer(NewClient(logrus.New()).WithBearerAuth("token")).WithBaseURL(base)
+
+	resp, err := r.Replay(context.Background(), &DataDump{RequestDump: dump})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.GetStatusCode())
+
+	assert.Equal(t, http.MethodPost, received.Method)
+	assert.Equal(t, "id=1", received.URL.RawQuery)
+	assert.Equal(t, `{"amount":10}`, body)
+	assert.Equal(t, jsonContentType, received.Header.Get(contentTypeHeaderKey))
+	assert.Equal(t, "Bearer token", received.Header.Get(authorizationHeaderKey))
+	assert.Equal(t, "original-id", received.Header.Get(requestIDHeaderKey))
+	assert.Equal(t, "1", received.Header.Get(attemptHeaderKey))
+
+	// without rewrite the captured host is used
+	req, err := NewReplayer(NewClient(logrus.New())).NewRequest(context.Background(), dump)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://partner.api/orders?id=1", req.URL.String())
+
+	_, err = r.Replay(context.Background(), &DataDump{RequestDump: []byte("invalid")})
+	assert.NotNil(t, err)
+}
+
+func TestReplayer_ReplayAuditFile(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var mu sync.Mutex
+	prices := map[string]string{"a": "1", "b": "2", "c": "3"}
+	mux.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		price, ok := prices[strings.TrimPrefix(r.URL.Path, "/items/")]
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		_, _ = w.Write([]byte(`{"price":` + price + `,"token":"t-` + price + `"}`))
+	})
+
+	// record the calls
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fw, err := NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	redactor := NewRedactor().WithParams("token")
+	sink := NewAuditSink(fw, redactor)
+
+	c := NewClient(logrus.New()).WithAuditSink(sink)
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := c.Get(context.Background(), u+"/items/"+id)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, sink.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Nil(t, err)
+	_, _ = f.WriteString("\n{invalid\n")
+	_ = f.Close()
+
+	// the responses changed meanwhile
+	mu.Lock()
+	prices["b"] = "20"
+	delete(prices, "c")
+	mu.Unlock()
+
+	results, err := NewReplayer(NewClient(logrus.New()).WithRetryMax(0)).
+		WithConcurrency(2).
+		WithRedactor(redactor).
+		ReplayAuditFile(context.Background(), path)
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+
+	assert.Equal(t, 1, results[0].Line)
+	assert.True(t, results[0].Match())
+	assert.Equal(t, u+"/items/a", results[0].Record.URL)
+
+	assert.Nil(t, results[1].Err)
+	assert.True(t, results[1].StatusMatch)
+	assert.False(t, results[1].BodyMatch)
+
+	assert.Nil(t, results[2].Err)
+	assert.False(t, results[2].StatusMatch)
+	assert.Equal(t, http.StatusNotFound, results[2].Response.GetStatusCode())
+
+	assert.Equal(t, 5, results[3].Line)
+	assert.NotNil(t, results[3].Err)
+	assert.False(t, results[3].Match())
+
+	_, err = NewReplayer(c).ReplayAuditFile(context.Background(), filepath.Join(t.TempDir(), "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestReplayer_ReplayAudit_withoutAttempts(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/cached", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, jsonContentType)
+		w.Header().Set(cacheControlHeaderKey, "max-age=60")
+		_, _ = w.Write([]byte(`{"cached":true}`))
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// record the calls
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	fw, err := NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	sink := NewAuditSink(fw, nil)
+
+	c := NewClient(logrus.New()).WithRetryMax(0).WithAuditSink(sink)
+	_, err = c.Get(context.Background(), u+"/cached")
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Close())
+
+	recorded, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+
+	// the second replay is a cache hit, without attempt
+	results, err := NewReplayer(NewClient(logrus.New()).WithCache(NewMemoryCacheStore(1<<20))).
+		ReplayAudit(context.Background(), strings.NewReader(string(recorded)+string(recorded)))
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, CacheHit, results[1].Response.CacheStatus)
+	assert.Empty(t, results[1].Response.AttemptDumps)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		assert.True(t, result.Match())
+	}
+
+	// the fallback response has no attempt either
+	down := strings.Replace(string(recorded), u+"/cached", u+"/down", 1)
+	fallback := NewClient(logrus.New()).WithRetryMax(0).WithFallback(func(req *Request, _ *Response, _ error) (*Response, error) {
+		return NewSyntheticResponse(req, http.StatusOK, nil, []byte(`{"cached":true}`)), nil
+	})
+	results, err = NewReplayer(fallback).ReplayAudit(context.Background(), strings.NewReader(down))
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Nil(t, results[0].Err)
+	assert.True(t, results[0].Response.Degraded)
+	assert.True(t, results[0].BodyMatch)
+}
+
+func Test_equalBodies(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, equalBodies([]byte("text"), []byte("text")))
+	assert.True(t, equalBodies([]byte(`{"a":1,"b":[1,2]}`), []byte(`{ "b": [1, 2], "a": 1 }`)))
+	assert.False(t, equalBodies([]byte(`{"a":1}`), []byte(`{"a":2}`)))
+	assert.False(t, equalBodies([]byte("text"), []byte("other")))
+}
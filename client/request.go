@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // Request wraps the metadata needed to create HTTP requests
@@ -15,10 +17,40 @@ type Request struct {
 
 	contentLength int64
 
+	// body before the compression, kept for the dumps and the curl commands
+	plainBody []byte
+
+	// skip the client auth, used for the requests of the auth flows
+	skipAuth bool
+
+	// the Authorization header was set by the client auth, and is resolved
+	// again for the URL of every attempt
+	authApplied bool
+
+	// fallback of the request, wins over the ones of the client
+	fallback FallbackFunc
+
+	// revalidate the stored response even when it can be served, used by the
+	// background revalidations
+	forceRevalidate bool
+
+	// stream the response body, see WithStreaming
+	streaming         bool
+	streamIdleTimeout time.Duration
+
+	// maximum size of the response body, wins over the one of the client
+	maxResponseSize int64
+
+	// relative URL resolved against the endpoint of the pool, see WithEndpointPool
+	relativeURL *url.URL
+	endpoint    *Endpoint
+
 	*http.Request
 }
 
-// NewRequest creates a new wrapped request with the provided context
+// NewRequest creates a new wrapped request with the provided context.
+// The raw body is JSON encoded, except url.Values which are form encoded
+// and io.Reader which is sent as it is
 func (c *BaseClient) NewRequest(ctx context.Context, method, url string, rawBody interface{}) (*Request, error) {
 	// get the body reader
 	bodyReader, err := getBodyReader(rawBody)
@@ -49,7 +81,7 @@ func (c *BaseClient) NewRequest(ctx context.Context, method, url string, rawBody
 		bodyReader = bytes.NewReader(b)
 	}
 
-	return &Request{bodyReader, contentLength, req}, nil
+	return &Request{body: bodyReader, contentLength: contentLength, Request: req}, nil
 }
 
 // SetHeader method is to set a single header key/value pair
@@ -70,6 +102,7 @@ func (r *Request) SetHeaders(headers map[string]string) *Request {
 func (r *Request) setupAuth(scheme, token string) error {
 	if scheme != "" && token != "" {
 		r.SetHeader(authorizationHeaderKey, fmt.Sprintf("%s %s", scheme, token))
+		r.authApplied = true
 	}
 	return nil
 }
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// crockfordAlphabet is the Crockford's base32 alphabet used by the ULIDs
+const crockfordAlphabet string = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// RequestIDGenerator generates the ID of a call without one in its context
+type RequestIDGenerator func() (string, error)
+
+// NewUUIDv4 returns a random (version 4) UUID
+func NewUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+
+	return string(buf), nil
+}
+
+// NewULID returns a ULID, a lexicographically sortable ID made of the
+// millisecond timestamp and 80 random bits
+func NewULID() (string, error) {
+	return newULID(time.Now())
+}
+
+// newULID returns a ULID of the time
+func newULID(t time.Time) (string, error) {
+	var b [16]byte
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	// encode the 128 bits as 26 base32 characters, from the least significant
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	buf := make([]byte, 26)
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(buf), nil
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of the context carrying the request ID,
+// which is sent by the client instead of a generated one
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by the context
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// WithRequestIDHeader sets the name of the request ID header and returns the
+// BaseClient. An empty name disables the request and the attempt headers
+func (c *BaseClient) WithRequestIDHeader(name string) *BaseClient {
+	c.requestIDHeader = name
+	return c
+}
+
+// WithRequestIDGenerator sets the generator of the request IDs and returns the BaseClient
+func (c *BaseClient) WithRequestIDGenerator(g RequestIDGenerator) *BaseClient {
+	if g != nil {
+		c.requestIDGenerator = g
+	}
+	return c
+}
+
+// requestID returns the ID of the call: the one already set on the request,
+// the one of the context or a generated one
+func (c *BaseClient) requestID(req *Request) (string, error) {
+	if c.requestIDHeader == "" {
+		return "", nil
+	}
+	if id := req.Header.Get(c.requestIDHeader); id != "" {
+		return id, nil
+	}
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		return id, nil
+	}
+	return c.requestIDGenerator()
+}
+
+// setAttemptHeaders sets the request ID and the attempt number headers
+func (c *BaseClient) setAttemptHeaders(req *Request, id string, attempt int) {
+	if c.requestIDHeader == "" {
+		return
+	}
+	req.SetHeader(c.requestIDHeader, id)
+	req.SetHeader(attemptHeaderKey, strconv.Itoa(attempt))
+}
+
+// RequestIDMiddleware returns a middleware storing the ID of the incoming
+// requests in their context, so the calls made while handling them carry it.
+// Requests without an ID get a generated one, which is also sent back in the
+// response header. The defaults are used for an empty header and a nil generator
+func RequestIDMiddleware(header string, generator RequestIDGenerator) func(http.Handler) http.Handler {
+	if header == "" {
+		header = requestIDHeaderKey
+	}
+	if generator == nil {
+		generator = NewUUIDv4
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if id == "" {
+				var err error
+				if id, err = generator(); err != nil {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+		})
+	}
+}
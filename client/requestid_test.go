@@ -0,0 +1,179 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUUIDv4(t *testing.T) {
+	t.Parallel()
+
+	id, err := NewUUIDv4()
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+
+	other, _ := NewUUIDv4()
+	assert.NotEqual(t, id, other)
+}
+
+func TestNewULID(t *testing.T) {
+	t.Parallel()
+
+	id, err := NewULID()
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), id)
+
+	// the timestamp is encoded in the first 10 characters
+	id, err = newULID(time.Unix(0, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, "0000000000", id[:10])
+
+	id, _ = newULID(time.Unix(1469918176, 385000000))
+	assert.Equal(t, "01ARYZ6S41", id[:10])
+
+	// sortable by time
+	earlier, _ := newULID(time.Unix(1000, 0))
+	later, _ := newULID(time.Unix(1001, 0))
+	assert.True(t, earlier < later)
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := RequestIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = RequestIDFromContext(ContextWithRequestID(context.Background(), ""))
+	assert.False(t, ok)
+
+	id, ok := RequestIDFromContext(ContextWithRequestID(context.Background(), "id"))
+	assert.True(t, ok)
+	assert.Equal(t, "id", id)
+}
+
+func TestBaseClient_Do_requestID(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var ids, attempts []string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(requestIDHeaderKey))
+		attempts = append(attempts, r.Header.Get(attemptHeaderKey))
+		if len(ids)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	c := NewClient(logger).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond })
+
+	// generated and kept across the attempts
+	resp, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Len(t, ids, 2)
+	assert.NotEmpty(t, ids[0])
+	assert.Equal(t, ids[0], ids[1])
+	assert.Equal(t, []string{"1", "2"}, attempts)
+	assert.Equal(t, ids[0], resp.RequestID)
+	for _, e := range hook.AllEntries() {
+		assert.Equal(t, ids[0], e.Data["request_id"])
+	}
+
+	// taken from the context
+	_, err = c.Get(ContextWithRequestID(context.Background(), "from-context"), u)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"from-context", "from-context"}, ids[2:])
+
+	// already set on the request
+	req, _ := c.NewRequest(context.Background(), http.MethodGet, u, nil)
+	req.SetHeader(requestIDHeaderKey, "preset")
+	resp, err = c.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "preset", ids[4])
+	assert.Equal(t, "preset", resp.RequestID)
+
+	// custom header and generator
+	var custom string
+	mux.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		custom = r.Header.Get("X-Correlation-ID")
+	})
+	c = NewClient(logrus.New()).
+		WithRequestIDHeader("X-Correlation-ID").
+		WithRequestIDGenerator(NewULID)
+	resp, err = c.Get(context.Background(), u+"/custom")
+	assert.Nil(t, err)
+	assert.Len(t, custom, 26)
+	assert.Equal(t, custom, resp.RequestID)
+
+	// disabled
+	c = NewClient(logrus.New()).WithRequestIDHeader("")
+	resp, err = c.Get(context.Background(), u+"/custom")
+	assert.Nil(t, err)
+	assert.Empty(t, custom)
+	assert.Empty(t, resp.RequestID)
+
+	// generation failure
+	c = NewClient(logrus.New()).WithRequestIDGenerator(func() (string, error) {
+		return "", errors.New("no entropy")
+	})
+	_, err = c.Get(context.Background(), u)
+	assert.EqualError(t, err, "no entropy")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var upstream string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Get(requestIDHeaderKey)
+	})
+
+	// the handler calls the upstream service with the ID of the incoming request
+	c := NewClient(logrus.New())
+	handler := RequestIDMiddleware("", nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = c.Get(r.Context(), u)
+	}))
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(requestIDHeaderKey, "incoming")
+	handler.ServeHTTP(rec, r)
+	assert.Equal(t, "incoming", upstream)
+	assert.Equal(t, "incoming", rec.Header().Get(requestIDHeaderKey))
+
+	// generated when missing
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotEmpty(t, upstream)
+	assert.NotEqual(t, "incoming", upstream)
+	assert.Equal(t, upstream, rec.Header().Get(requestIDHeaderKey))
+
+	// generation failure
+	handler = RequestIDMiddleware("X-Correlation-ID", func() (string, error) {
+		return "", errors.New("no entropy")
+	})(http.NotFoundHandler())
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -2,10 +2,16 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
+// ErrNotModified is returned when decoding the empty body of a 304 Not Modified
+// response which wasn't turned into the stored one, see WithConditionalRequests
+var ErrNotModified = errors.New("not modified")
+
 // DataDump is a struct containing the request and the response
 type DataDump struct {
 	RequestDump  []byte
@@ -17,6 +23,49 @@ type Response struct {
 	RawResponse *http.Response
 
 	DataDump *DataDump
+
+	// RequestID is the ID sent in the request ID header
+	RequestID string
+
+	// Timings holds the timing breakdown of every attempt
+	Timings []AttemptTiming
+
+	// AttemptDumps holds the request and the response of every attempt,
+	// in the order of Timings. The response bodies of the attempts before the
+	// final one are only kept when requested, see WithAttemptBodyDumps
+	AttemptDumps []*DataDump
+
+	// TLS holds the connection and peer certificate details, nil for plain HTTP
+	TLS *TLSInfo
+
+	// Deprecation holds the retirement announcement of the endpoint, nil when there is none
+	Deprecation *Deprecation
+
+	// CacheStatus tells how the cache served the response, empty when not cached
+	CacheStatus CacheStatus
+
+	// NotModified is true when the server answered 304 Not Modified. The
+	// response of a revalidation holds the stored status, headers and body,
+	// otherwise the body is empty
+	NotModified bool
+
+	// Degraded is true when the response isn't the answer of the server to the
+	// call: a stale response served on error or the response of a fallback
+	Degraded bool
+
+	// DegradedCause is the error of the call the degraded response replaces
+	DegradedCause error
+
+	// Coalesced is true when the response is a copy of the one of an identical
+	// call in flight, see WithRequestCoalescing
+	Coalesced bool
+
+	// Stream is the body of a streamed response, nil otherwise. The body of
+	// RawResponse reads from it, see Request.WithStreaming
+	Stream *BodyStream
+
+	// URL of the request
+	requestURL *url.URL
 }
 
 // GetStatus returns the status string of the response
@@ -54,6 +103,9 @@ func (r *Response) GetStringBody() (string, error) {
 
 // UnmarshalJSONResponse unmarshalls the response body into the provided target object
 func (r *Response) UnmarshalJSONResponse(target interface{}) error {
+	if r.RawResponse != nil && r.RawResponse.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
 	b, err := r.GetBody()
 	if err != nil {
 		return err
@@ -256,6 +256,19 @@ func TestResponse_UnmarshalJSON(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "not modified",
+			fields: fields{
+				RawResponse: &http.Response{
+					StatusCode: http.StatusNotModified,
+					Body:       ioutil.NopCloser(strings.NewReader("")),
+				},
+			},
+			args: args{
+				target: p,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -37,6 +38,11 @@ func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bo
 // default retry policy
 func baseRetryPolicy(resp *http.Response, err error) (bool, error) {
 	if err != nil {
+		// oversized body or decompression bomb - no retry
+		if errors.Is(err, ErrBodyTooLarge) || errors.Is(err, ErrDecodedBodyTooLarge) {
+			return false, err
+		}
+
 		if v, ok := err.(*url.Error); ok {
 			// to too many redirects - no retry
 			if redirectsErrorRe.MatchString(v.Error()) {
@@ -63,6 +69,11 @@ func baseRetryPolicy(resp *http.Response, err error) (bool, error) {
 		return true, nil
 	}
 
+	// 304 Not Modified answers a conditional request, the stored response is current
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+
 	// 429 Too Many Requests
 	if resp.StatusCode == http.StatusTooManyRequests {
 		return true, nil
@@ -103,6 +103,15 @@ func Test_baseRetryPolicy(t *testing.T) {
 			want:    true,
 			wantErr: false,
 		},
+		{
+			name: "status code 304",
+			args: args{
+				resp: &http.Response{StatusCode: http.StatusNotModified},
+				err:  nil,
+			},
+			want:    false,
+			wantErr: false,
+		},
 		{
 			name: "status code 0",
 			args: args{
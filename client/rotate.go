@@ -0,0 +1,199 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the timestamp suffix of the rotated files
+const rotatedTimeFormat string = "20060102T150405.000000000"
+
+// RotatingFileWriter is an io.WriteCloser appending to a file which is rotated
+// once it reaches the maximum size or age. The rotated files are renamed with
+// a timestamp suffix and the oldest ones are removed past the maximum backups
+type RotatingFileWriter struct {
+	path string
+
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	closed   bool
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewRotatingFileWriter creates a new RotatingFileWriter appending to the file of the path
+func NewRotatingFileWriter(path string) (*RotatingFileWriter, error) {
+	w := &RotatingFileWriter{path: path, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WithMaxSize sets the size in bytes which triggers the rotation and returns the RotatingFileWriter
+func (w *RotatingFileWriter) WithMaxSize(size int64) *RotatingFileWriter {
+	w.maxSize = size
+	return w
+}
+
+// WithMaxAge sets the age of the file which triggers the rotation and returns the RotatingFileWriter
+func (w *RotatingFileWriter) WithMaxAge(age time.Duration) *RotatingFileWriter {
+	w.maxAge = age
+	return w
+}
+
+// WithMaxBackups sets the number of rotated files to keep and returns the
+// RotatingFileWriter. Zero keeps all of them
+func (w *RotatingFileWriter) WithMaxBackups(n int) *RotatingFileWriter {
+	w.maxBackups = n
+	return w
+}
+
+// Write appends the data to the file, rotating it first when needed. The
+// data is never split between two files, a failed rotation keeps appending to
+// the current file and is returned
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.reopen(); err != nil {
+		return 0, err
+	}
+
+	var rotateErr error
+	if w.size > 0 && (w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize ||
+		w.maxAge > 0 && w.now().Sub(w.openedAt) >= w.maxAge) {
+		if rotateErr = w.rotate(); w.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate rotates the file
+func (w *RotatingFileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.reopen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// Close closes the file
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// reopen opens the file again when a rotation failed to, the closed writer fails
+func (w *RotatingFileWriter) reopen() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		return w.open()
+	}
+	return nil
+}
+
+// open opens the file for appending
+func (w *RotatingFileWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.openedAt = w.now()
+	return nil
+}
+
+// rotate renames the current file, opens a new one and removes the old backups.
+// The current file is opened again when it can't be renamed
+func (w *RotatingFileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		w.file = nil
+		return err
+	}
+
+	if renameErr := os.Rename(w.path, w.path+"."+w.now().UTC().Format(rotatedTimeFormat)); renameErr != nil {
+		if err := w.open(); err != nil {
+			w.file = nil
+		}
+		return renameErr
+	}
+	if err := w.open(); err != nil {
+		w.file = nil
+		return err
+	}
+
+	return w.removeOldBackups()
+}
+
+// backups returns the rotated files, oldest first
+func (w *RotatingFileWriter) backups() ([]string, error) {
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := w.path + "."
+	var result []string
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(m, prefix)); err == nil {
+			result = append(result, m)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// removeOldBackups removes the oldest rotated files past the maximum backups
+func (w *RotatingFileWriter) removeOldBackups() error {
+	if w.maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > w.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFileWriter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	w, err := NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	w.now = func() time.Time { return now }
+	w = w.WithMaxSize(10).WithMaxAge(time.Hour).WithMaxBackups(2)
+
+	// rotated by size, the data isn't split
+	_, err = w.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	now = now.Add(time.Second)
+	_, err = w.Write([]byte("abc\n"))
+	assert.Nil(t, err)
+
+	backups, err := w.backups()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{path + ".20220101T000001.000000000"}, backups)
+	b, _ := ioutil.ReadFile(backups[0])
+	assert.Equal(t, "12345678\n", string(b))
+
+	// rotated by age
+	now = now.Add(time.Hour)
+	_, err = w.Write([]byte("def\n"))
+	assert.Nil(t, err)
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "def\n", string(b))
+
+	// the oldest backups are removed
+	now = now.Add(time.Second)
+	assert.Nil(t, w.Rotate())
+	backups, _ = w.backups()
+	assert.Len(t, backups, 2)
+	assert.Equal(t, path+".20220101T010001.000000000", backups[0])
+
+	assert.Nil(t, w.Close())
+	assert.Nil(t, w.Close())
+	_, err = w.Write([]byte("x"))
+	assert.Equal(t, os.ErrClosed, err)
+
+	// appends to the existing file
+	w, err = NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	_, _ = w.Write([]byte("ghi\n"))
+	assert.Nil(t, w.Close())
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "ghi\n", string(b))
+
+	_, err = NewRotatingFileWriter(filepath.Join(path, "invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestRotatingFileWriter_renameFailure(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	w, err := NewRotatingFileWriter(path)
+	assert.Nil(t, err)
+	w.now = func() time.Time { return now }
+	w = w.WithMaxSize(10)
+
+	// a non-empty directory can't be replaced by the rotated file
+	backup := path + "." + now.Format(rotatedTimeFormat)
+	assert.Nil(t, os.MkdirAll(filepath.Join(backup, "busy"), 0700))
+
+	_, err = w.Write([]byte("12345678\n"))
+	assert.Nil(t, err)
+	assert.NotNil(t, w.Rotate())
+
+	// the data is still appended to the file, the failed rotation is returned
+	n, err := w.Write([]byte("abc\n"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, n)
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "12345678\nabc\n", string(b))
+
+	// the rotation succeeds once the rename does
+	assert.Nil(t, os.RemoveAll(backup))
+	_, err = w.Write([]byte("def\n"))
+	assert.Nil(t, err)
+	b, _ = ioutil.ReadFile(backup)
+	assert.Equal(t, "12345678\nabc\n", string(b))
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "def\n", string(b))
+	assert.Nil(t, w.Close())
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+)
+
+// CSRFConfig configures where the CSRF token is captured from and
+// how it is sent back
+type CSRFConfig struct {
+	// CookieName is the name of the cookie holding the token
+	CookieName string
+
+	// ResponseHeader is the name of the response header holding the token,
+	// only sent back to the host of the response
+	ResponseHeader string
+
+	// RequestHeader is the name of the header used to send the token on
+	// unsafe requests. It defaults to "X-CSRF-Token"
+	RequestHeader string
+}
+
+// Session keeps a cookie based login session and injects the CSRF token
+// into the unsafe (POST, PUT, PATCH, DELETE) requests of the client
+type Session struct {
+	client *BaseClient
+	csrf   CSRFConfig
+
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// NewSession creates a new Session bound to the client. A cookie jar is
+// set on the client if it doesn't have one already
+func NewSession(c *BaseClient, csrf CSRFConfig) *Session {
+	if csrf.RequestHeader == "" {
+		csrf.RequestHeader = csrfTokenHeaderKey
+	}
+
+	s := &Session{
+		client: c,
+		csrf:   csrf,
+		tokens: make(map[string]string),
+	}
+
+	if c.hc == nil || c.hc.Jar == nil {
+		c.WithCookieJar(nil)
+	}
+	c.session = s
+
+	return s
+}
+
+// Login performs the login request and captures the CSRF token
+func (s *Session) Login(req *Request) (*Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if code := resp.GetStatusCode(); code < 200 || code > 299 {
+		return resp, fmt.Errorf("login failed: unexpected HTTP status %s", resp.GetStatus())
+	}
+
+	if s.csrf.CookieName != "" || s.csrf.ResponseHeader != "" {
+		if s.Token(req.URL) == "" && s.cookieToken(req) == "" {
+			return resp, fmt.Errorf("login failed: no CSRF token found in the response")
+		}
+	}
+
+	return resp, nil
+}
+
+// Token returns the last CSRF token captured from a response header of the
+// host of the URL
+func (s *Session) Token(u *url.URL) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens[canonicalHost(u)]
+}
+
+// inject sets the CSRF token on unsafe requests
+func (s *Session) inject(req *Request) {
+	if isSafeMethod(req.Method) {
+		return
+	}
+
+	token := s.cookieToken(req)
+	if token == "" {
+		token = s.Token(req.URL)
+	}
+	if token != "" {
+		req.SetHeader(s.csrf.RequestHeader, token)
+	}
+}
+
+// capture stores the CSRF token sent in the response header, if any, for
+// the host of the response
+func (s *Session) capture(resp *http.Response) {
+	if resp == nil || resp.Request == nil || s.csrf.ResponseHeader == "" {
+		return
+	}
+
+	if token := resp.Header.Get(s.csrf.ResponseHeader); token != "" {
+		s.mu.Lock()
+		s.tokens[canonicalHost(resp.Request.URL)] = token
+		s.mu.Unlock()
+	}
+}
+
+// cookieToken returns the CSRF token from the cookie jar for the request URL
+func (s *Session) cookieToken(req *Request) string {
+	if s.csrf.CookieName == "" || s.client.hc == nil || s.client.hc.Jar == nil {
+		return ""
+	}
+
+	for _, c := range s.client.hc.Jar.Cookies(req.URL) {
+		if c.Name == s.csrf.CookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// WithCookieJar sets the cookie jar of the http client and returns the BaseClient.
+// A nil jar creates a new in-memory net/http/cookiejar
+func (c *BaseClient) WithCookieJar(jar http.CookieJar) *BaseClient {
+	if jar == nil {
+		// cookiejar.New never returns an error
+		jar, _ = cookiejar.New(nil)
+	}
+	if c.hc == nil {
+		c.hc = getHTTPClient()
+	}
+	c.hc.Jar = jar
+	return c
+}
+
+// isSafeMethod checks if the method is safe as defined by RFC 7231
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseClient_WithCookieJar(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	c := NewClient(logrus.New()).WithRetryMax(0)
+	assert.Nil(t, c.hc.Jar)
+
+	c = c.WithCookieJar(nil)
+	assert.NotNil(t, c.hc.Jar)
+
+	_, err := c.Post(ctx, u+"/login", "application/json", nil)
+	assert.Nil(t, err)
+
+	response, err := c.Get(ctx, u+"/me")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.GetStatusCode())
+}
+
+func TestSession_Login(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("cookie token", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "t1", Path: "/"})
+		})
+
+		var csrf []string
+		mux.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+			csrf = append(csrf, r.Header.Get("X-CSRFToken"))
+		})
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		s := NewSession(c, CSRFConfig{CookieName: "csrftoken", RequestHeader: "X-CSRFToken"})
+		assert.NotNil(t, c.hc.Jar)
+
+		req, err := c.NewRequest(ctx, http.MethodPost, u+"/login", map[string]string{"username": "u"})
+		assert.Nil(t, err)
+
+		_, err = s.Login(req)
+		assert.Nil(t, err)
+
+		_, err = c.Get(ctx, u+"/products")
+		assert.Nil(t, err)
+		_, err = c.Post(ctx, u+"/products", "application/json", map[string]string{"name": "p"})
+		assert.Nil(t, err)
+		_, err = c.Delete(ctx, u+"/products")
+		assert.Nil(t, err)
+
+		// the token is only sent on unsafe requests
+		assert.Equal(t, []string{"", "t1", "t1"}, csrf)
+	})
+
+	t.Run("header token", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-CSRF-Token", "t1")
+		})
+
+		var csrf []string
+		mux.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+			csrf = append(csrf, r.Header.Get("X-CSRF-Token"))
+			// the token is rotated
+			w.Header().Set("X-CSRF-Token", "t2")
+		})
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		s := NewSession(c, CSRFConfig{ResponseHeader: "X-CSRF-Token"})
+
+		req, err := c.NewRequest(ctx, http.MethodPost, u+"/login", nil)
+		assert.Nil(t, err)
+
+		_, err = s.Login(req)
+		assert.Nil(t, err)
+		assert.Equal(t, "t1", s.Token(req.URL))
+
+		_, err = c.Put(ctx, u+"/products", "application/json", nil)
+		assert.Nil(t, err)
+		_, err = c.Patch(ctx, u+"/products", "application/json", nil)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"t1", "t2"}, csrf)
+
+		// the token isn't sent to the other hosts
+		otherMux, other, otherShutdown := setup()
+		defer otherShutdown()
+
+		var otherCSRF []string
+		otherMux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+			otherCSRF = append(otherCSRF, r.Header.Get("X-CSRF-Token"))
+		})
+
+		_, err = c.Post(ctx, other+"/upload", "application/json", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{""}, otherCSRF)
+
+		otherURL, _ := url.Parse(other)
+		assert.Equal(t, "", s.Token(otherURL))
+	})
+
+	t.Run("failed login", func(t *testing.T) {
+		mux, u, shutdown := setup()
+		defer shutdown()
+
+		mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+		mux.HandleFunc("/no-token", func(w http.ResponseWriter, r *http.Request) {})
+
+		c := NewClient(logrus.New()).WithRetryMax(0)
+		s := NewSession(c, CSRFConfig{CookieName: "csrftoken"})
+
+		req, err := c.NewRequest(ctx, http.MethodPost, u+"/login", nil)
+		assert.Nil(t, err)
+
+		response, err := s.Login(req)
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, response.GetStatusCode())
+
+		req, err = c.NewRequest(ctx, http.MethodPost, u+"/no-token", nil)
+		assert.Nil(t, err)
+
+		_, err = s.Login(req)
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_isSafeMethod(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isSafeMethod(http.MethodGet))
+	assert.True(t, isSafeMethod(http.MethodHead))
+	assert.True(t, isSafeMethod(http.MethodOptions))
+	assert.False(t, isSafeMethod(http.MethodPost))
+	assert.False(t, isSafeMethod(http.MethodDelete))
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamIdleTimeout is returned when reading a streamed body receives no
+// data within the idle timeout
+var ErrStreamIdleTimeout = errors.New("stream idle timeout")
+
+// BodyStream is the body of a streamed response. It counts the bytes read and
+// cancels the call when a read waits longer than the idle timeout
+type BodyStream struct {
+	body   io.ReadCloser
+	size   int64
+	cancel context.CancelFunc
+
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+
+	read     int64
+	timedOut int32
+
+	closeOnce sync.Once
+}
+
+// newBodyStream wraps the body, the cancellation aborts the call of the body
+func newBodyStream(body io.ReadCloser, size int64, idleTimeout time.Duration, cancel context.CancelFunc) *BodyStream {
+	s := &BodyStream{body: body, size: size, cancel: cancel, idleTimeout: idleTimeout}
+	if idleTimeout > 0 {
+		s.idleTimer = time.AfterFunc(idleTimeout, func() {
+			atomic.StoreInt32(&s.timedOut, 1)
+			cancel()
+		})
+		s.idleTimer.Stop()
+	}
+	return s
+}
+
+// Read reads the body, the time the caller spends between the reads doesn't
+// count as idle
+func (s *BodyStream) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&s.timedOut) == 1 {
+		return 0, ErrStreamIdleTimeout
+	}
+
+	if s.idleTimer != nil {
+		s.idleTimer.Reset(s.idleTimeout)
+	}
+	n, err := s.body.Read(p)
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	atomic.AddInt64(&s.read, int64(n))
+
+	if err != nil && err != io.EOF && atomic.LoadInt32(&s.timedOut) == 1 {
+		err = ErrStreamIdleTimeout
+	}
+	return n, err
+}
+
+// Close closes the body and releases the call
+func (s *BodyStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.idleTimer != nil {
+			s.idleTimer.Stop()
+		}
+		err = s.body.Close()
+		s.cancel()
+	})
+	return err
+}
+
+// BytesRead returns the number of body bytes read so far
+func (s *BodyStream) BytesRead() int64 {
+	return atomic.LoadInt64(&s.read)
+}
+
+// Size returns the announced length of the body, -1 when unknown
+func (s *BodyStream) Size() int64 {
+	return s.size
+}
+
+// WithStreaming streams the response bodies of every call, and returns the
+// BaseClient. See Request.WithStreaming
+func (c *BaseClient) WithStreaming(idleTimeout time.Duration) *BaseClient {
+	c.streaming = true
+	c.streamIdleTimeout = idleTimeout
+	return c
+}
+
+// WithStreaming streams the response body of the request, and returns the
+// Request. The call returns once the headers arrive and the body is read from
+// Response.Stream, which the caller must close; nothing buffers it unless the
+// caller does, e.g. with GetBody. A read waiting longer than the idle timeout
+// fails with ErrStreamIdleTimeout, zero disables the timeout. The streamed
+// calls skip the cache and the coalescing, their dumps hold no response body
+func (r *Request) WithStreaming(idleTimeout time.Duration) *Request {
+	r.streaming = true
+	r.streamIdleTimeout = idleTimeout
+	return r
+}
+
+// streams checks if the response body of the request is streamed, and returns its idle timeout
+func (c *BaseClient) streams(req *Request) (bool, time.Duration) {
+	if req.streaming {
+		return true, req.streamIdleTimeout
+	}
+	return c.streaming, c.streamIdleTimeout
+}
+
+// dumpResponseHead dumps the status line and the headers of the response,
+// without reading its body
+func dumpResponseHead(resp *http.Response) ([]byte, error) {
+	head := *resp
+	head.Header = resp.Header.Clone()
+	head.Header.Del(contentLengthHeaderKey)
+	head.Body = http.NoBody
+	head.ContentLength = -1
+	head.TransferEncoding = nil
+	return httputil.DumpResponse(&head, false)
+}
@@ -0,0 +1,125 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_WithStreaming(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	release := make(chan struct{})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Export", "orders")
+		_, _ = w.Write([]byte("first,"))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write([]byte("second"))
+	})
+
+	c := NewClient(logrus.New())
+	req, err := c.NewRequest(context.Background(), http.MethodGet, u, nil)
+	assert.Nil(t, err)
+
+	// the call returns before the body is complete
+	resp, err := c.Do(req.WithStreaming(time.Second))
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.Stream)
+	assert.Equal(t, "orders", resp.GetHeaders().Get("X-Export"))
+	assert.Equal(t, int64(-1), resp.Stream.Size())
+
+	buf := make([]byte, len("first,"))
+	_, err = io.ReadFull(resp.Stream, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "first,", string(buf))
+	assert.Equal(t, int64(len("first,")), resp.Stream.BytesRead())
+
+	close(release)
+	rest, err := resp.GetStringBody()
+	assert.Nil(t, err)
+	assert.Equal(t, "second", rest)
+	assert.Equal(t, int64(len("first,second")), resp.Stream.BytesRead())
+
+	// the dumps hold the headers only
+	assert.Contains(t, string(resp.DataDump.ResponseDump), "X-Export: orders")
+	assert.NotContains(t, string(resp.DataDump.ResponseDump), "first")
+}
+
+func TestRequest_WithStreaming_idleTimeout(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	mux.HandleFunc("/stalled", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/complete", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 1024)))
+	})
+
+	c := NewClient(logrus.New()).WithStreaming(50 * time.Millisecond)
+
+	// the stalled server fails the read
+	resp, err := c.Get(context.Background(), u+"/stalled")
+	assert.Nil(t, err)
+	b, err := ioutil.ReadAll(resp.Stream)
+	assert.True(t, errors.Is(err, ErrStreamIdleTimeout))
+	assert.Equal(t, "partial", string(b))
+	assert.Nil(t, resp.Stream.Close())
+
+	// a slow reader isn't idle
+	resp, err = c.Get(context.Background(), u+"/complete")
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	body, err := resp.GetBody()
+	assert.Nil(t, err)
+	assert.Len(t, body, 1024)
+}
+
+func TestBaseClient_WithStreaming_skipsCache(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int32
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(cacheControlHeaderKey, "max-age=60")
+		_, _ = w.Write([]byte("export"))
+	})
+
+	c := NewClient(logrus.New()).
+		WithCache(NewMemoryCacheStore(1 << 20)).
+		WithRequestCoalescing().
+		WithStreaming(0)
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(context.Background(), u)
+		assert.Nil(t, err)
+		assert.Empty(t, resp.CacheStatus)
+		body, err := resp.GetStringBody()
+		assert.Nil(t, err)
+		assert.Equal(t, "export", body)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
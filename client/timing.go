@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AttemptTiming is the timing breakdown of a single attempt. The durations
+// are encoded in JSON as nanoseconds
+type AttemptTiming struct {
+	// Attempt is the 1-based number of the attempt
+	Attempt int `json:"attempt"`
+
+	// Start is the time the attempt started at
+	Start time.Time `json:"start"`
+
+	// DNSLookup is the time spent resolving the host, zero for reused connections
+	DNSLookup time.Duration `json:"dns_lookup"`
+
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration `json:"connect"`
+
+	// TLSHandshake is the time spent on the TLS handshake
+	TLSHandshake time.Duration `json:"tls_handshake"`
+
+	// ServerProcessing is the time between writing the request and the first response byte
+	ServerProcessing time.Duration `json:"server_processing"`
+
+	// TimeToFirstByte is the time between the start of the attempt and the first response byte
+	TimeToFirstByte time.Duration `json:"time_to_first_byte"`
+
+	// Total is the duration of the attempt, including the read of the buffered
+	// response body. It ends with the headers when the body is streamed or
+	// discarded, see WithAttemptBodyDumps
+	Total time.Duration `json:"total"`
+
+	// Backoff is the wait before the next attempt, zero for the last one
+	Backoff time.Duration `json:"backoff"`
+
+	// ConnReused is true when the connection was taken from the idle pool
+	ConnReused bool `json:"conn_reused"`
+
+	// RemoteAddr is the address of the server the connection is made to
+	RemoteAddr string `json:"remote_addr"`
+
+	// StatusCode is the response status code, zero on transport errors
+	StatusCode int `json:"status_code"`
+}
+
+// fields returns the timing as logger fields
+func (t AttemptTiming) fields() logrus.Fields {
+	return logrus.Fields{
+		"attempt":            t.Attempt,
+		"dns_lookup":         t.DNSLookup,
+		"connect":            t.Connect,
+		"tls_handshake":      t.TLSHandshake,
+		"server_processing":  t.ServerProcessing,
+		"time_to_first_byte": t.TimeToFirstByte,
+		"total":              t.Total,
+		"conn_reused":        t.ConnReused,
+		"remote_addr":        t.RemoteAddr,
+	}
+}
+
+// attemptTimer collects the connection events of an attempt. The hooks may
+// be called concurrently, e.g. when dialing several addresses
+type attemptTimer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	end          time.Time
+
+	reused     bool
+	remoteAddr string
+}
+
+// newAttemptTimer creates a new attemptTimer started now
+func newAttemptTimer() *attemptTimer {
+	return &attemptTimer{start: time.Now()}
+}
+
+// record stores the time of the event in the field
+func (t *attemptTimer) record(field *time.Time, once bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if once && !field.IsZero() {
+		return
+	}
+	*field = time.Now()
+}
+
+// withContext returns a copy of the context carrying the hooks of the timer
+func (t *attemptTimer) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) { t.record(&t.dnsStart, true) },
+		DNSDone:  func(_ httptrace.DNSDoneInfo) { t.record(&t.dnsDone, false) },
+		// with several addresses, the first dial start and the last dial end are kept
+		ConnectStart:      func(_, _ string) { t.record(&t.connectStart, true) },
+		ConnectDone:       func(_, _ string, _ error) { t.record(&t.connectDone, false) },
+		TLSHandshakeStart: func() { t.record(&t.tlsStart, true) },
+		TLSHandshakeDone:  func(_ tls.ConnectionState, _ error) { t.record(&t.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.reused = info.Reused
+			if info.Conn != nil && info.Conn.RemoteAddr() != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		WroteRequest:         func(_ httptrace.WroteRequestInfo) { t.record(&t.wroteRequest, false) },
+		GotFirstResponseByte: func() { t.record(&t.firstByte, true) },
+	})
+}
+
+// finish stops the timer and returns the timing of the attempt
+func (t *attemptTimer) finish(attempt, statusCode int) AttemptTiming {
+	t.record(&t.end, true)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return AttemptTiming{
+		Attempt:          attempt,
+		Start:            t.start,
+		DNSLookup:        between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLSHandshake:     between(t.tlsStart, t.tlsDone),
+		ServerProcessing: between(t.wroteRequest, t.firstByte),
+		TimeToFirstByte:  between(t.start, t.firstByte),
+		Total:            between(t.start, t.end),
+		ConnReused:       t.reused,
+		RemoteAddr:       t.remoteAddr,
+		StatusCode:       statusCode,
+	}
+}
+
+// between returns the duration between the times or zero when one is missing
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
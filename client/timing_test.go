@@ -0,0 +1,89 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_between(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	assert.Equal(t, time.Second, between(now, now.Add(time.Second)))
+	assert.Equal(t, time.Duration(0), between(time.Time{}, now))
+	assert.Equal(t, time.Duration(0), between(now, time.Time{}))
+	assert.Equal(t, time.Duration(0), between(now.Add(time.Second), now))
+}
+
+func TestBaseClient_Do_timings(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var calls int
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	c := NewClient(logger).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond })
+
+	resp, err := c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Len(t, resp.Timings, 2)
+
+	first, second := resp.Timings[0], resp.Timings[1]
+	assert.Equal(t, 1, first.Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, first.StatusCode)
+	assert.False(t, first.ConnReused)
+	assert.True(t, first.Connect > 0)
+	assert.Equal(t, time.Millisecond, first.Backoff)
+	assert.Equal(t, strings.TrimPrefix(u, "http://"), first.RemoteAddr)
+
+	assert.Equal(t, 2, second.Attempt)
+	assert.Equal(t, http.StatusOK, second.StatusCode)
+	assert.True(t, second.ConnReused)
+	assert.Equal(t, time.Duration(0), second.Connect)
+	assert.Equal(t, time.Duration(0), second.Backoff)
+	assert.True(t, second.ServerProcessing >= 5*time.Millisecond)
+	assert.True(t, second.TimeToFirstByte >= second.ServerProcessing)
+	assert.True(t, second.Total >= second.TimeToFirstByte)
+
+	// the timings are logged
+	var logged []logrus.Fields
+	for _, e := range hook.AllEntries() {
+		if strings.HasSuffix(e.Message, "timing") {
+			logged = append(logged, e.Data)
+		}
+	}
+	assert.Len(t, logged, 2)
+	assert.Equal(t, 2, logged[1]["attempt"])
+	assert.Equal(t, true, logged[1]["conn_reused"])
+	assert.Equal(t, second.ServerProcessing, logged[1]["server_processing"])
+
+	// the timings are kept on failures
+	resp, err = c.WithRetryMax(0).Get(context.Background(), "http://127.0.0.1:0")
+	assert.NotNil(t, err)
+	assert.Len(t, resp.Timings, 1)
+	assert.Equal(t, 0, resp.Timings[0].StatusCode)
+}
@@ -0,0 +1,259 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// certificateWarningInterval is the minimum time between two expiry warnings of a certificate
+const certificateWarningInterval time.Duration = 24 * time.Hour
+
+// OCSP statuses of the stapled response
+const (
+	OCSPStatusNone    string = "none"
+	OCSPStatusGood    string = "good"
+	OCSPStatusRevoked string = "revoked"
+	OCSPStatusUnknown string = "unknown"
+	OCSPStatusInvalid string = "invalid"
+)
+
+// TLS protocol version names
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// TLSInfo holds the details of the TLS connection of the response
+type TLSInfo struct {
+	// Version is the negotiated protocol version, e.g. tls.VersionTLS13
+	Version uint16
+
+	// VersionName is the name of the version, e.g. "TLS 1.3"
+	VersionName string
+
+	// CipherSuite is the negotiated cipher suite
+	CipherSuite uint16
+
+	// CipherSuiteName is the name of the cipher suite
+	CipherSuiteName string
+
+	// NegotiatedProtocol is the ALPN protocol, e.g. "h2"
+	NegotiatedProtocol string
+
+	// ServerName is the SNI sent to the server
+	ServerName string
+
+	// DidResume is true when the session was resumed
+	DidResume bool
+
+	// OCSPStatus is the status of the stapled OCSP response, OCSPStatusNone
+	// when the server didn't staple one. Its signature isn't verified
+	OCSPStatus string
+
+	// PeerCertificates is the certificate chain sent by the server, leaf first
+	PeerCertificates []CertificateInfo
+}
+
+// CertificateInfo holds the details of a certificate
+type CertificateInfo struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	DNSNames     []string
+	IPAddresses  []string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	IsCA         bool
+
+	// Fingerprint is the hex encoded SHA-256 of the certificate
+	Fingerprint string
+}
+
+// newTLSInfo returns the details of the connection state
+func newTLSInfo(cs *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:            cs.Version,
+		VersionName:        tlsVersionNames[cs.Version],
+		CipherSuite:        cs.CipherSuite,
+		CipherSuiteName:    tls.CipherSuiteName(cs.CipherSuite),
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+		ServerName:         cs.ServerName,
+		DidResume:          cs.DidResume,
+		OCSPStatus:         ocspStatus(cs.OCSPResponse),
+	}
+	if info.VersionName == "" {
+		info.VersionName = fmt.Sprintf("0x%04X", cs.Version)
+	}
+
+	for _, cert := range cs.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, newCertificateInfo(cert))
+	}
+
+	return info
+}
+
+// newCertificateInfo returns the details of the certificate
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	info := CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		DNSNames:     cert.DNSNames,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		IsCA:         cert.IsCA,
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+
+	return info
+}
+
+// ASN.1 structures of the OCSP response, see RFC 6960
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []ocspSingleResponse
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// ocspStatus returns the certificate status of the stapled OCSP response
+func ocspStatus(der []byte) string {
+	if len(der) == 0 {
+		return OCSPStatusNone
+	}
+
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil || len(rest) > 0 || resp.Status != 0 {
+		return OCSPStatusInvalid
+	}
+
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil || len(basic.TBSResponseData.Responses) == 0 {
+		return OCSPStatusInvalid
+	}
+
+	// the status is a context-specific tag: good [0], revoked [1] or unknown [2]
+	status := basic.TBSResponseData.Responses[0].CertStatus
+	if status.Class != asn1.ClassContextSpecific {
+		return OCSPStatusInvalid
+	}
+	switch status.Tag {
+	case 0:
+		return OCSPStatusGood
+	case 1:
+		return OCSPStatusRevoked
+	case 2:
+		return OCSPStatusUnknown
+	}
+	return OCSPStatusInvalid
+}
+
+// CertificateExpiryHook is called when a peer certificate expires soon
+type CertificateExpiryHook func(host string, cert CertificateInfo, remaining time.Duration)
+
+// certificateWatcher fires the expiry hook, at most once a day per certificate
+type certificateWatcher struct {
+	within time.Duration
+	hook   CertificateExpiryHook
+	now    func() time.Time
+
+	mu     sync.Mutex
+	warned map[string]time.Time
+}
+
+// WithCertificateExpiryWarning sets the hook called when a certificate of the
+// chain sent by a server expires within the number of days, and returns the
+// BaseClient. The hook is called at most once a day per certificate, a nil
+// hook disables the warning
+func (c *BaseClient) WithCertificateExpiryWarning(days int, hook CertificateExpiryHook) *BaseClient {
+	if hook == nil {
+		c.certificateWatcher = nil
+		return c
+	}
+	c.certificateWatcher = &certificateWatcher{
+		within: time.Duration(days) * 24 * time.Hour,
+		hook:   hook,
+		now:    time.Now,
+		warned: make(map[string]time.Time),
+	}
+	return c
+}
+
+// check calls the hook for the certificates expiring soon
+func (w *certificateWatcher) check(host string, info *TLSInfo) {
+	now := w.now()
+
+	for _, cert := range info.PeerCertificates {
+		remaining := cert.NotAfter.Sub(now)
+		if remaining > w.within || !w.shouldWarn(cert.Fingerprint, now) {
+			continue
+		}
+		w.hook(host, cert, remaining)
+	}
+}
+
+// shouldWarn checks if the certificate wasn't warned about recently and records the warning.
+// The warnings which expired are forgotten, only the ones of the last day are kept
+func (w *certificateWatcher) shouldWarn(fingerprint string, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if last, ok := w.warned[fingerprint]; ok && now.Sub(last) < certificateWarningInterval {
+		return false
+	}
+
+	for fp, last := range w.warned {
+		if now.Sub(last) >= certificateWarningInterval {
+			delete(w.warned, fp)
+		}
+	}
+	w.warned[fingerprint] = now
+	return true
+}
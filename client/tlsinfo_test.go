@@ -0,0 +1,204 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1 expiring after the duration
+func newTestCertificate(t *testing.T, validity time.Duration) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "api.local"},
+		DNSNames:              []string{"api.local"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+// newTestOCSPResponse returns an unsigned OCSP response with the status tag
+func newTestOCSPResponse(t *testing.T, tag int, compound bool) []byte {
+	status := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: compound}
+	if compound {
+		status.Bytes, _ = asn1.Marshal(time.Now().UTC())
+	}
+	responderKey, _ := asn1.Marshal([]byte("responder"))
+
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData: ocspResponseData{
+			RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: responderKey},
+			ProducedAt:     time.Now().UTC().Truncate(time.Second),
+			Responses: []ocspSingleResponse{{
+				CertID: ocspCertID{
+					HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+					IssuerNameHash: []byte{1},
+					IssuerKeyHash:  []byte{2},
+					SerialNumber:   big.NewInt(42),
+				},
+				CertStatus: status,
+				ThisUpdate: time.Now().UTC().Truncate(time.Second),
+			}},
+		},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+	})
+	assert.Nil(t, err)
+
+	der, err := asn1.Marshal(ocspResponse{
+		Response: ocspResponseBytes{
+			ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1},
+			Response:     basic,
+		},
+	})
+	assert.Nil(t, err)
+	return der
+}
+
+func Test_ocspStatus(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, OCSPStatusNone, ocspStatus(nil))
+	assert.Equal(t, OCSPStatusGood, ocspStatus(newTestOCSPResponse(t, 0, false)))
+	assert.Equal(t, OCSPStatusRevoked, ocspStatus(newTestOCSPResponse(t, 1, true)))
+	assert.Equal(t, OCSPStatusUnknown, ocspStatus(newTestOCSPResponse(t, 2, false)))
+	assert.Equal(t, OCSPStatusInvalid, ocspStatus([]byte("invalid")))
+
+	// unsuccessful response status
+	der, _ := asn1.Marshal(ocspResponse{Status: 6})
+	assert.Equal(t, OCSPStatusInvalid, ocspStatus(der))
+}
+
+func TestBaseClient_Do_TLS(t *testing.T) {
+	t.Parallel()
+
+	cert, leaf := newTestCertificate(t, 10*24*time.Hour)
+	cert.OCSPStaple = newTestOCSPResponse(t, 0, false)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	var mu sync.Mutex
+	var warnings []CertificateInfo
+	c := NewClient(logrus.New()).WithCertificateExpiryWarning(30, func(host string, cert CertificateInfo, remaining time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "127.0.0.1", host)
+		assert.True(t, remaining > 9*24*time.Hour && remaining <= 10*24*time.Hour)
+		warnings = append(warnings, cert)
+	})
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	c.hc.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(context.Background(), server.URL)
+		assert.Nil(t, err)
+		assert.NotNil(t, resp.TLS)
+
+		info := resp.TLS
+		assert.Equal(t, uint16(tls.VersionTLS13), info.Version)
+		assert.Equal(t, "TLS 1.3", info.VersionName)
+		assert.NotEmpty(t, info.CipherSuiteName)
+		assert.Equal(t, "h2", info.NegotiatedProtocol)
+		assert.Equal(t, OCSPStatusGood, info.OCSPStatus)
+		assert.Len(t, info.PeerCertificates, 1)
+
+		peer := info.PeerCertificates[0]
+		assert.Equal(t, "CN=api.local", peer.Subject)
+		assert.Equal(t, "CN=api.local", peer.Issuer)
+		assert.Equal(t, "42", peer.SerialNumber)
+		assert.Equal(t, []string{"api.local"}, peer.DNSNames)
+		assert.Equal(t, []string{"127.0.0.1"}, peer.IPAddresses)
+		assert.True(t, peer.NotAfter.Equal(leaf.NotAfter))
+		assert.Len(t, peer.Fingerprint, 64)
+	}
+
+	// warned once a day
+	mu.Lock()
+	assert.Len(t, warnings, 1)
+	mu.Unlock()
+
+	// plain HTTP
+	_, u, shutdown := setup()
+	defer shutdown()
+	resp, err := NewClient(logrus.New()).Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Nil(t, resp.TLS)
+}
+
+func Test_certificateWatcher_check(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	var warned []string
+	c := NewClient(logrus.New()).WithCertificateExpiryWarning(7, func(_ string, cert CertificateInfo, _ time.Duration) {
+		warned = append(warned, cert.Fingerprint)
+	})
+	w := c.certificateWatcher
+	w.now = func() time.Time { return now }
+
+	info := &TLSInfo{PeerCertificates: []CertificateInfo{
+		{Fingerprint: "leaf", NotAfter: now.Add(24 * time.Hour)},
+		{Fingerprint: "root", NotAfter: now.Add(365 * 24 * time.Hour)},
+		{Fingerprint: "expired", NotAfter: now.Add(-time.Hour)},
+	}}
+
+	w.check("api.local", info)
+	assert.Equal(t, []string{"leaf", "expired"}, warned)
+
+	w.check("api.local", info)
+	assert.Len(t, warned, 2)
+
+	// warned again the next day
+	now = now.Add(certificateWarningInterval)
+	w.check("api.local", info)
+	assert.Equal(t, []string{"leaf", "expired", "leaf", "expired"}, warned)
+
+	// the expired warnings are forgotten
+	now = now.Add(certificateWarningInterval)
+	w.check("api.local", &TLSInfo{PeerCertificates: info.PeerCertificates[:1]})
+	assert.Len(t, w.warned, 1)
+
+	// a nil hook disables the warning
+	c.WithCertificateExpiryWarning(7, nil)
+	assert.Nil(t, c.certificateWatcher)
+}
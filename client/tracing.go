@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// W3C Trace Context header keys
+const (
+	traceParentHeaderKey string = "traceparent"
+	traceStateHeaderKey  string = "tracestate"
+)
+
+// Span attribute keys
+const (
+	AttributeHTTPMethod     string = "http.method"
+	AttributeHTTPURL        string = "http.url"
+	AttributeHTTPStatusCode string = "http.status_code"
+	AttributeHTTPAttempt    string = "http.attempt"
+	AttributeHTTPRetryCount string = "http.retry_count"
+)
+
+// SpanContext identifies a span as defined by the W3C Trace Context
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	TraceFlags byte
+	TraceState string
+}
+
+// IsValid checks if both the trace and the span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent returns the value of the "traceparent" header
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.TraceFlags)
+}
+
+// ParseTraceParent parses the values of the "traceparent" and "tracestate" headers
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+	// version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid traceparent %q: %w", traceParent, err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid traceparent %q: %w", traceParent, err)
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, fmt.Errorf("invalid traceparent %q: %w", traceParent, err)
+	}
+	sc.TraceFlags = flags[0]
+	sc.TraceState = strings.TrimSpace(traceState)
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: zero trace or span ID", traceParent)
+	}
+
+	return sc, nil
+}
+
+// spanContextKey is the context key of the SpanContext
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of the context carrying the span context, e.g.
+// the one of an incoming request, which is propagated when no Tracer is set
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by the context
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Span is a single operation of a trace
+type Span interface {
+	// SpanContext returns the span context injected in the outgoing headers
+	SpanContext() SpanContext
+
+	// SetAttribute sets an attribute of the span
+	SetAttribute(key string, value interface{})
+
+	// RecordError records the error and marks the span as failed
+	RecordError(err error)
+
+	// End completes the span
+	End()
+}
+
+// Tracer starts spans. A span started from a context carrying another span is its child
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// WithTracer sets the tracer and returns the BaseClient. Do opens a span for the
+// logical call and a child span for every attempt
+func (c *BaseClient) WithTracer(t Tracer) *BaseClient {
+	c.tracer = t
+	return c
+}
+
+// injectTraceContext sets the W3C Trace Context headers of the request
+func injectTraceContext(req *Request, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	req.SetHeader(traceParentHeaderKey, sc.TraceParent())
+	if sc.TraceState != "" {
+		req.SetHeader(traceStateHeaderKey, sc.TraceState)
+	} else {
+		req.Header.Del(traceStateHeaderKey)
+	}
+}
+
+// recordSpanResult records the status code and the error of the call on the span
+func recordSpanResult(span Span, resp *Response, err error) {
+	if resp != nil && resp.RawResponse != nil {
+		span.SetAttribute(AttributeHTTPStatusCode, resp.RawResponse.StatusCode)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+}
@@ -0,0 +1,195 @@
+//go:build !integration
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSpan is a Span recording its data
+type fakeSpan struct {
+	name       string
+	parent     *fakeSpan
+	sc         SpanContext
+	attributes map[string]interface{}
+	errs       []error
+	ended      bool
+}
+
+func (s *fakeSpan) SpanContext() SpanContext { return s.sc }
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+
+func (s *fakeSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+
+func (s *fakeSpan) End() { s.ended = true }
+
+type fakeSpanKey struct{}
+
+// fakeTracer is a Tracer recording its spans
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &fakeSpan{name: name, attributes: map[string]interface{}{}}
+	s.sc.SpanID[7] = byte(len(t.spans) + 1)
+	s.sc.TraceState = "vendor=value"
+
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		s.parent = parent
+		s.sc.TraceID = parent.sc.TraceID
+	} else {
+		s.sc.TraceID[15] = 1
+	}
+
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, fakeSpanKey{}, s), s
+}
+
+func TestParseTraceParent(t *testing.T) {
+	t.Parallel()
+
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE")
+	assert.Nil(t, err)
+	assert.True(t, sc.IsValid())
+	assert.Equal(t, byte(1), sc.TraceFlags)
+	assert.Equal(t, "congo=t61rcWkgMzE", sc.TraceState)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+
+	// future versions may append fields
+	_, err = ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "")
+	assert.Nil(t, err)
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-zbf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, err := ParseTraceParent(v, "")
+		assert.NotNil(t, err, v)
+	}
+}
+
+func TestSpanContextFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := SpanContextFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := ContextWithSpanContext(context.Background(), SpanContext{})
+	_, ok = SpanContextFromContext(ctx)
+	assert.False(t, ok)
+
+	sc, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	ctx = ContextWithSpanContext(context.Background(), sc)
+	result, ok := SpanContextFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, sc, result)
+}
+
+func TestBaseClient_WithTracer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var traceParents, traceStates []string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		traceParents = append(traceParents, r.Header.Get(traceParentHeaderKey))
+		traceStates = append(traceStates, r.Header.Get(traceStateHeaderKey))
+		if len(traceParents) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	tracer := &fakeTracer{}
+	c := NewClient(logrus.New()).
+		WithRetryMax(1).
+		WithBackoffStrategy(func(_ int) time.Duration { return time.Millisecond }).
+		WithTracer(tracer)
+
+	_, err := c.Get(ctx, u)
+	assert.Nil(t, err)
+
+	assert.Len(t, tracer.spans, 3)
+
+	call := tracer.spans[0]
+	assert.Equal(t, "HTTP GET", call.name)
+	assert.Nil(t, call.parent)
+	assert.True(t, call.ended)
+	assert.Equal(t, map[string]interface{}{
+		AttributeHTTPMethod:     http.MethodGet,
+		AttributeHTTPURL:        u,
+		AttributeHTTPStatusCode: http.StatusOK,
+		AttributeHTTPRetryCount: 1,
+	}, call.attributes)
+
+	for i, attempt := range tracer.spans[1:] {
+		assert.Equal(t, "HTTP GET attempt", attempt.name)
+		assert.Equal(t, call, attempt.parent)
+		assert.True(t, attempt.ended)
+		assert.Equal(t, i+1, attempt.attributes[AttributeHTTPAttempt])
+		assert.Equal(t, attempt.sc.TraceParent(), traceParents[i])
+		assert.Equal(t, "vendor=value", traceStates[i])
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, tracer.spans[1].attributes[AttributeHTTPStatusCode])
+	assert.Equal(t, http.StatusOK, tracer.spans[2].attributes[AttributeHTTPStatusCode])
+
+	// errors are recorded
+	tracer.spans = nil
+	_, err = c.WithRetryMax(0).Get(ctx, "http://127.0.0.1:0")
+	assert.NotNil(t, err)
+	assert.Len(t, tracer.spans, 2)
+	assert.Len(t, tracer.spans[0].errs, 1)
+	assert.Len(t, tracer.spans[1].errs, 1)
+}
+
+func TestBaseClient_Do_propagatesSpanContext(t *testing.T) {
+	t.Parallel()
+
+	mux, u, shutdown := setup()
+	defer shutdown()
+
+	var traceParent, traceState string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get(traceParentHeaderKey)
+		traceState = r.Header.Get(traceStateHeaderKey)
+	})
+
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE")
+	assert.Nil(t, err)
+
+	ctx := ContextWithSpanContext(context.Background(), sc)
+	c := NewClient(logrus.New()).WithRetryMax(0)
+
+	_, err = c.Get(ctx, u)
+	assert.Nil(t, err)
+	assert.Equal(t, sc.TraceParent(), traceParent)
+	assert.Equal(t, "congo=t61rcWkgMzE", traceState)
+
+	// no headers without a span context
+	_, err = c.Get(context.Background(), u)
+	assert.Nil(t, err)
+	assert.Empty(t, traceParent)
+	assert.Empty(t, traceState)
+}
@@ -1,25 +1,40 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-// drainBody reads the body
-func drainBody(body io.ReadCloser) error {
+// drainBody reads the body up to the limit and closes it
+func drainBody(body io.ReadCloser, limit int64) error {
 	defer body.Close()
-	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, responseReadLimit))
+	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, limit))
 	return err
 }
 
 // getBodyReader encodes the payload into the body reader
-// and returns it
+// and returns it. An io.Reader is used as it is, url.Values are
+// form encoded and everything else is JSON encoded
 func getBodyReader(rawBody interface{}) (io.Reader, error) {
 	var bodyReader io.Reader
 
+	switch v := rawBody.(type) {
+	case io.Reader:
+		return v, nil
+	case url.Values:
+		return strings.NewReader(v.Encode()), nil
+	}
+
 	if rawBody != nil {
 		requestByte, err := json.Marshal(rawBody)
 		if err != nil {
@@ -47,3 +62,71 @@ func basicAuth(username, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
+
+// writeFileAtomic writes the data to a temporary file first and renames it,
+// so a crash never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// readRequestDump parses the request dump and returns the request and its body
+func readRequestDump(dump []byte) (*http.Request, []byte, error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(dump)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading request dump: %w", err)
+	}
+	body, err := readAndClose(req.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading request dump: %w", err)
+	}
+	return req, body, nil
+}
+
+// readResponseDump parses the response dump of the request and returns the
+// response and its body
+func readResponseDump(dump []byte, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response dump: %w", err)
+	}
+	body, err := readAndClose(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response dump: %w", err)
+	}
+	return resp, body, nil
+}
+
+// readAndClose reads the whole body and closes it
+func readAndClose(body io.ReadCloser) ([]byte, error) {
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// dumpURL returns the absolute URL of the dumped request, based on the URL of the call
+func dumpURL(base *url.URL, req *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: req.Host}
+	if base != nil {
+		copied := *base
+		u = &copied
+	}
+	u.Path, u.RawPath, u.RawQuery = req.URL.Path, req.URL.RawPath, req.URL.RawQuery
+	return u
+}
@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -17,12 +19,21 @@ func Test_drainBody(t *testing.T) {
 	t.Parallel()
 
 	body := io.NopCloser(strings.NewReader("test"))
-	err := drainBody(body)
+	err := drainBody(body, defaultDrainLimit)
 	assert.Nil(t, err)
 
 	b, err := ioutil.ReadAll(body)
 	assert.Nil(t, err)
 	assert.Empty(t, b)
+
+	// the rest beyond the limit isn't read
+	body = io.NopCloser(strings.NewReader("test"))
+	err = drainBody(body, 2)
+	assert.Nil(t, err)
+
+	b, err = ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, "st", string(b))
 }
 
 func Test_getBodyReader(t *testing.T) {
@@ -51,6 +62,22 @@ func Test_getBodyReader(t *testing.T) {
 	assert.Equal(t, r, body)
 }
 
+func Test_getBodyReader_raw(t *testing.T) {
+	t.Parallel()
+
+	br, err := getBodyReader(url.Values{"a": {"1"}, "b": {"x y"}})
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadAll(br)
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1&b=x+y", string(b))
+
+	r := strings.NewReader("raw")
+	br, err = getBodyReader(r)
+	assert.Nil(t, err)
+	assert.Equal(t, r, br)
+}
+
 func Test_getContentLength(t *testing.T) {
 	t.Parallel()
 
@@ -78,3 +105,19 @@ func Test_basicAuth(t *testing.T) {
 	result := basicAuth("u", "p")
 	assert.Equal(t, "dTpw", result)
 }
+
+func Test_writeFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "file")
+
+	err := writeFileAtomic(path, []byte("data"), 0600)
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(b))
+
+	err = writeFileAtomic(filepath.Join(path, "not-a-dir"), []byte("data"), 0600)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,94 @@
+// Package otelclient adapts an OpenTelemetry tracer to the client.Tracer interface,
+// so the core module stays free of the OpenTelemetry dependency.
+//
+// The module builds against the core module of the repository through the
+// replace directive of its go.mod, until a core release with the tracer exists
+package otelclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer
+const instrumentationName string = "github.com/barbucatalinn/go-http-client"
+
+// Tracer is a client.Tracer backed by an OpenTelemetry tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a new Tracer from the provider. A nil provider uses the global one
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start starts a client span, child of the span carried by the context
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, client.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, &span{s}
+}
+
+// span adapts an OpenTelemetry span to the client.Span interface
+type span struct {
+	span trace.Span
+}
+
+// SpanContext returns the W3C span context of the span
+func (s *span) SpanContext() client.SpanContext {
+	return toSpanContext(s.span.SpanContext())
+}
+
+// toSpanContext converts an OpenTelemetry span context
+func toSpanContext(sc trace.SpanContext) client.SpanContext {
+	return client.SpanContext{
+		TraceID:    sc.TraceID(),
+		SpanID:     sc.SpanID(),
+		TraceFlags: byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+	}
+}
+
+// SetAttribute sets an attribute of the span
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(toAttribute(key, value))
+}
+
+// RecordError records the error and sets the span status to error
+func (s *span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End completes the span
+func (s *span) End() {
+	s.span.End()
+}
+
+// toAttribute converts the value to an OpenTelemetry attribute
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	}
+	return attribute.String(key, fmt.Sprint(value))
+}
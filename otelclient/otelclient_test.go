@@ -0,0 +1,79 @@
+package otelclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/barbucatalinn/go-http-client/client"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	c := client.NewClient(logrus.New()).WithRetryMax(0).WithTracer(NewTracer(tp))
+
+	_, err := c.Get(context.Background(), server.URL)
+	assert.Nil(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	attempt, call := spans[0], spans[1]
+	assert.Equal(t, "HTTP GET attempt", attempt.Name())
+	assert.Equal(t, "HTTP GET", call.Name())
+	assert.Equal(t, call.SpanContext().SpanID(), attempt.Parent().SpanID())
+	assert.Equal(t, call.SpanContext().TraceID(), attempt.SpanContext().TraceID())
+
+	// the attempt span is propagated
+	assert.Equal(t, toSpanContext(attempt.SpanContext()).TraceParent(), traceParent)
+
+	assert.Contains(t, call.Attributes(), attribute.Int(client.AttributeHTTPStatusCode, http.StatusNotImplemented))
+	assert.Contains(t, call.Attributes(), attribute.Int(client.AttributeHTTPRetryCount, 0))
+	assert.Contains(t, attempt.Attributes(), attribute.Int(client.AttributeHTTPAttempt, 1))
+}
+
+func TestTracer_error(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	c := client.NewClient(logrus.New()).WithRetryMax(0).WithTracer(NewTracer(tp))
+
+	_, err := c.Get(context.Background(), "http://127.0.0.1:0")
+	assert.NotNil(t, err)
+
+	for _, s := range recorder.Ended() {
+		assert.Equal(t, codes.Error, s.Status().Code)
+		assert.Len(t, s.Events(), 1)
+	}
+}
+
+func Test_toAttribute(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, attribute.String("k", "v"), toAttribute("k", "v"))
+	assert.Equal(t, attribute.Int("k", 1), toAttribute("k", 1))
+	assert.Equal(t, attribute.Int64("k", 1), toAttribute("k", int64(1)))
+	assert.Equal(t, attribute.Bool("k", true), toAttribute("k", true))
+	assert.Equal(t, attribute.Float64("k", 1.5), toAttribute("k", 1.5))
+	assert.Equal(t, attribute.String("k", "[1 2]"), toAttribute("k", []int{1, 2}))
+}